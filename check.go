@@ -0,0 +1,335 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/alecthomas/kingpin/v2"
+)
+
+// Exit codes of monitoring plugins.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStateNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+var (
+	checkCmd = kingpin.Command("check", "Check the DSL line of the target as a Nagios/Icinga plugin. Thresholds use the monitoring plugin range format, for example 6: alerts below 6")
+
+	checkSNRMarginWarning    = checkCmd.Flag("snr-margin.warning", "Warning range for the SNR margin in dB").Default("6:").String()
+	checkSNRMarginCritical   = checkCmd.Flag("snr-margin.critical", "Critical range for the SNR margin in dB").Default("3:").String()
+	checkAttenuationWarning  = checkCmd.Flag("attenuation.warning", "Warning range for the attenuation in dB").Default("").String()
+	checkAttenuationCritical = checkCmd.Flag("attenuation.critical", "Critical range for the attenuation in dB").Default("").String()
+	checkRateRatioWarning    = checkCmd.Flag("rate-ratio.warning", "Warning range for the ratio of the actual to the attainable rate").Default("").String()
+	checkRateRatioCritical   = checkCmd.Flag("rate-ratio.critical", "Critical range for the ratio of the actual to the attainable rate").Default("").String()
+	checkCRCRateWarning      = checkCmd.Flag("crc-rate.warning", "Warning range for the CRC errors per minute since the previous check").Default("").String()
+	checkCRCRateCritical     = checkCmd.Flag("crc-rate.critical", "Critical range for the CRC errors per minute since the previous check").Default("").String()
+	checkStateFile           = checkCmd.Flag("state-file", "File to keep the CRC error counters in between checks, required for the CRC thresholds").Default("").String()
+)
+
+var errInvalidRange = errors.New("invalid threshold range")
+
+// thresholdRange is a threshold in the monitoring plugin range format:
+// "10" alerts outside 0 to 10, "10:" below 10, "~:10" above 10, "10:20"
+// outside 10 to 20 and "@10:20" inside 10 to 20.
+type thresholdRange struct {
+	raw    string
+	start  float64
+	end    float64
+	inside bool
+}
+
+// parseRange parses s, it returns nil if s is empty.
+func parseRange(s string) (*thresholdRange, error) {
+	if s == "" {
+		return nil, nil
+	}
+	r := &thresholdRange{raw: s, end: math.Inf(1)}
+	spec := s
+	if rest, ok := strings.CutPrefix(spec, "@"); ok {
+		r.inside = true
+		spec = rest
+	}
+	start, end, hasStart := strings.Cut(spec, ":")
+	if !hasStart {
+		start, end = "", spec
+	}
+
+	var err error
+	switch start {
+	case "":
+	case "~":
+		r.start = math.Inf(-1)
+	default:
+		if r.start, err = strconv.ParseFloat(start, 64); err != nil {
+			return nil, fmt.Errorf("%w %q", errInvalidRange, s)
+		}
+	}
+	if end != "" {
+		if r.end, err = strconv.ParseFloat(end, 64); err != nil {
+			return nil, fmt.Errorf("%w %q", errInvalidRange, s)
+		}
+	}
+	if r.start > r.end {
+		return nil, fmt.Errorf("%w %q", errInvalidRange, s)
+	}
+	return r, nil
+}
+
+// alert reports whether v triggers the threshold. A nil range never alerts.
+func (r *thresholdRange) alert(v float64) bool {
+	if r == nil {
+		return false
+	}
+	outside := v < r.start || v > r.end
+	return outside != r.inside
+}
+
+func (r *thresholdRange) String() string {
+	if r == nil {
+		return ""
+	}
+	return r.raw
+}
+
+// checkThresholds are the warning and critical ranges of a value.
+type checkThresholds struct {
+	warning  *thresholdRange
+	critical *thresholdRange
+}
+
+func parseThresholds(warning string, critical string) (checkThresholds, error) {
+	w, err := parseRange(warning)
+	if err != nil {
+		return checkThresholds{}, err
+	}
+	c, err := parseRange(critical)
+	if err != nil {
+		return checkThresholds{}, err
+	}
+	return checkThresholds{warning: w, critical: c}, nil
+}
+
+// checkResult collects the state, messages and perfdata of a check.
+type checkResult struct {
+	state    int
+	messages []string
+	perfdata []string
+}
+
+func (r *checkResult) setState(state int) {
+	if state > r.state {
+		r.state = state
+	}
+}
+
+// evaluate compares a value against its thresholds and records its perfdata.
+func (r *checkResult) evaluate(label string, description string, value float64, unit string, t checkThresholds) {
+	r.perfdata = append(r.perfdata, strings.TrimRight(fmt.Sprintf("%s=%s;%s;%s", label, formatCheckValue(value), t.warning, t.critical), ";"))
+	switch {
+	case t.critical.alert(value):
+		r.setState(checkCritical)
+	case t.warning.alert(value):
+		r.setState(checkWarning)
+	default:
+		return
+	}
+	r.messages = append(r.messages, fmt.Sprintf("%s %s%s", description, formatCheckValue(value), unit))
+}
+
+// formatCheckValue rounds v to two decimals.
+func formatCheckValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// output returns the plugin output. The messages of alerting values replace
+// summary.
+func (r *checkResult) output(summary string) string {
+	if len(r.messages) > 0 {
+		summary = strings.Join(r.messages, ", ")
+	}
+	output := fmt.Sprintf("DSL %s - %s", checkStateNames[r.state], summary)
+	if len(r.perfdata) > 0 {
+		output += " | " + strings.Join(r.perfdata, " ")
+	}
+	return output
+}
+
+// print writes the plugin output and returns the exit code.
+func (r *checkResult) print(summary string) int {
+	fmt.Println(r.output(summary))
+	return r.state
+}
+
+// evaluateCRCRate compares the CRC errors per minute since the previous check,
+// kept in the state file at path, against t and updates the state file.
+func (r *checkResult) evaluateCRCRate(path string, status vigorv5.Status, now time.Time, t checkThresholds) error {
+	prev, err := readCheckState(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// The first check has nothing to compare against.
+	case err != nil:
+		return fmt.Errorf("unable to read state file: %w", err)
+	case status.CrcNearEnd < prev.CrcNearEnd || status.CrcFarEnd < prev.CrcFarEnd:
+		// The counters were reset by a resync or reboot.
+	default:
+		if minutes := now.Sub(prev.Time).Minutes(); minutes > 0 {
+			r.evaluate("crc_rate_near_end", "CRC errors per minute near end",
+				float64(status.CrcNearEnd-prev.CrcNearEnd)/minutes, "", t)
+			r.evaluate("crc_rate_far_end", "CRC errors per minute far end",
+				float64(status.CrcFarEnd-prev.CrcFarEnd)/minutes, "", t)
+		}
+	}
+	err = writeCheckState(path, checkState{
+		Time:       now,
+		CrcNearEnd: status.CrcNearEnd,
+		CrcFarEnd:  status.CrcFarEnd,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write state file: %w", err)
+	}
+	return nil
+}
+
+func checkFailed(state int, format string, a ...any) int {
+	fmt.Printf("DSL %s - %s\n", checkStateNames[state], fmt.Sprintf(format, a...))
+	return state
+}
+
+// checkState is kept in the state file between checks.
+type checkState struct {
+	Time       time.Time `json:"time"`
+	CrcNearEnd int       `json:"crc_near_end"`
+	CrcFarEnd  int       `json:"crc_far_end"`
+}
+
+func readCheckState(path string) (checkState, error) {
+	var state checkState
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	return state, json.Unmarshal(b, &state)
+}
+
+// writeCheckState replaces the state file atomically, so concurrent checks
+// never read a partially written file.
+func writeCheckState(path string, state checkState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// runCheck fetches the DSL status of the device, prints the plugin output and
+// returns the plugin exit code.
+func runCheck(fetcher StatusFetcher, login *loginManager) int {
+	if fetcher == nil {
+		return checkFailed(checkUnknown, "the check requires a single --target")
+	}
+
+	var thresholds [4]checkThresholds
+	for i, flags := range [][2]*string{
+		{checkSNRMarginWarning, checkSNRMarginCritical},
+		{checkAttenuationWarning, checkAttenuationCritical},
+		{checkRateRatioWarning, checkRateRatioCritical},
+		{checkCRCRateWarning, checkCRCRateCritical},
+	} {
+		t, err := parseThresholds(*flags[0], *flags[1])
+		if err != nil {
+			return checkFailed(checkUnknown, "%s", err)
+		}
+		thresholds[i] = t
+	}
+	snrMargin, attenuation, rateRatio, crcRate := thresholds[0], thresholds[1], thresholds[2], thresholds[3]
+	if (crcRate.warning != nil || crcRate.critical != nil) && *checkStateFile == "" {
+		return checkFailed(checkUnknown, "the CRC thresholds require --state-file")
+	}
+
+	login.loginOnce()
+	err := login.acquire()
+	var status vigorv5.Status
+	if err == nil {
+		status, err = fetcher.FetchStatus()
+	}
+	login.release()
+	if err != nil {
+		return checkFailed(checkUnknown, "unable to get DSL status: %s: %s", downReason(err), err)
+	}
+	now := time.Now()
+
+	if status.ActualRateDownstream == 0 {
+		return checkFailed(checkCritical, "line is down, status %q", status.Status)
+	}
+
+	var r checkResult
+	r.evaluate("snr_margin_downstream", "SNR margin downstream", status.SNRMarginDownstream, " dB", snrMargin)
+	r.evaluate("snr_margin_upstream", "SNR margin upstream", status.SNRMarginUpstream, " dB", snrMargin)
+	r.evaluate("attenuation_near_end", "attenuation near end", status.AttenuationNearEnd, " dB", attenuation)
+	r.evaluate("attenuation_far_end", "attenuation far end", status.AttenuationFarEnd, " dB", attenuation)
+	if status.AttainableRateDownstream > 0 {
+		r.evaluate("rate_ratio_downstream", "actual/attainable rate downstream",
+			float64(status.ActualRateDownstream)/float64(status.AttainableRateDownstream), "", rateRatio)
+	}
+	if status.AttainableRateUpstream > 0 {
+		r.evaluate("rate_ratio_upstream", "actual/attainable rate upstream",
+			float64(status.ActualRateUpstream)/float64(status.AttainableRateUpstream), "", rateRatio)
+	}
+	r.perfdata = append(r.perfdata,
+		fmt.Sprintf("actual_rate_downstream=%d", status.ActualRateDownstream),
+		fmt.Sprintf("actual_rate_upstream=%d", status.ActualRateUpstream),
+		fmt.Sprintf("crc_near_end=%dc", status.CrcNearEnd),
+		fmt.Sprintf("crc_far_end=%dc", status.CrcFarEnd),
+	)
+
+	if *checkStateFile != "" {
+		if err := r.evaluateCRCRate(*checkStateFile, status, now, crcRate); err != nil {
+			return checkFailed(checkUnknown, "%s", err)
+		}
+	}
+
+	state := status.Status
+	if state == "" {
+		state = "up"
+	}
+	return r.print(fmt.Sprintf("line %s, %d/%d kbps", state,
+		status.ActualRateDownstream/1000, status.ActualRateUpstream/1000))
+}
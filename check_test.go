@@ -0,0 +1,218 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+)
+
+func mustParseThresholds(t *testing.T, warning string, critical string) checkThresholds {
+	t.Helper()
+	thresholds, err := parseThresholds(warning, critical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return thresholds
+}
+
+func TestParseRange(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		start  float64
+		end    float64
+		inside bool
+		err    bool
+	}{
+		{in: "10", start: 0, end: 10},
+		{in: "10:", start: 10, end: math.Inf(1)},
+		{in: "~:10", start: math.Inf(-1), end: 10},
+		{in: "10:20", start: 10, end: 20},
+		{in: "@10:20", start: 10, end: 20, inside: true},
+		{in: "-5:-1", start: -5, end: -1},
+		{in: "20:10", err: true},
+		{in: "ten", err: true},
+		{in: "10:x", err: true},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			r, err := parseRange(tc.in)
+			if tc.err {
+				if !errors.Is(err, errInvalidRange) {
+					t.Errorf("got error %v, want %v", err, errInvalidRange)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.start != tc.start || r.end != tc.end || r.inside != tc.inside {
+				t.Errorf("got start %v end %v inside %t, want start %v end %v inside %t",
+					r.start, r.end, r.inside, tc.start, tc.end, tc.inside)
+			}
+			if r.String() != tc.in {
+				t.Errorf("got %q, want %q", r.String(), tc.in)
+			}
+		})
+	}
+
+	r, err := parseRange("")
+	if r != nil || err != nil {
+		t.Errorf("got %v %v for an empty range, want nil", r, err)
+	}
+}
+
+func TestThresholdRangeAlert(t *testing.T) {
+	for _, tc := range []struct {
+		r     string
+		value float64
+		want  bool
+	}{
+		{"10", -1, true},
+		{"10", 0, false},
+		{"10", 10, false},
+		{"10", 11, true},
+		{"10:", 9.9, true},
+		{"10:", 10, false},
+		{"~:10", -1000, false},
+		{"~:10", 10.1, true},
+		{"@10:20", 9, false},
+		{"@10:20", 10, true},
+		{"@10:20", 20, true},
+		{"@10:20", 21, false},
+		{"", 1000, false},
+	} {
+		r, err := parseRange(tc.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.alert(tc.value); got != tc.want {
+			t.Errorf("%q.alert(%v): got %t, want %t", tc.r, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestCheckResultEvaluate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		value    float64
+		state    int
+		messages []string
+	}{
+		{name: "ok", value: 7.5, state: checkOK},
+		{name: "warning", value: 4.25, state: checkWarning, messages: []string{"SNR margin 4.25 dB"}},
+		{name: "critical", value: 2.123, state: checkCritical, messages: []string{"SNR margin 2.12 dB"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var r checkResult
+			r.evaluate("snr_margin", "SNR margin", tc.value, " dB", mustParseThresholds(t, "6:", "3:"))
+			if r.state != tc.state {
+				t.Errorf("got state %d, want %d", r.state, tc.state)
+			}
+			if !reflect.DeepEqual(r.messages, tc.messages) {
+				t.Errorf("got messages %q, want %q", r.messages, tc.messages)
+			}
+		})
+	}
+}
+
+func TestCheckResultOutput(t *testing.T) {
+	var r checkResult
+	r.evaluate("snr_margin_downstream", "SNR margin downstream", 7, " dB", mustParseThresholds(t, "6:", "3:"))
+	r.evaluate("attenuation_near_end", "attenuation near end", 12.345, " dB", mustParseThresholds(t, "", ""))
+	r.evaluate("rate_ratio_downstream", "actual/attainable rate downstream", 0.5, "", mustParseThresholds(t, "0.8:", ""))
+
+	want := "DSL WARNING - actual/attainable rate downstream 0.5 | snr_margin_downstream=7;6:;3: attenuation_near_end=12.35 rate_ratio_downstream=0.5;0.8:"
+	if got := r.output("line up"); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	var ok checkResult
+	if got := ok.output("line up, 80000/20000 kbps"); got != "DSL OK - line up, 80000/20000 kbps" {
+		t.Errorf("got %q without alerts", got)
+	}
+}
+
+func TestEvaluateCRCRate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	thresholds := mustParseThresholds(t, "~:1", "~:10")
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name     string
+		after    time.Duration
+		near     int
+		far      int
+		state    int
+		perfdata []string
+	}{
+		// The first check has no previous counters.
+		{name: "first", near: 100, far: 10, state: checkOK},
+		{name: "ok", after: time.Minute, near: 100, far: 11, state: checkOK,
+			perfdata: []string{"crc_rate_near_end=0;~:1;~:10", "crc_rate_far_end=1;~:1;~:10"}},
+		{name: "warning", after: 2 * time.Minute, near: 104, far: 11, state: checkWarning,
+			perfdata: []string{"crc_rate_near_end=4;~:1;~:10", "crc_rate_far_end=0;~:1;~:10"}},
+		{name: "critical", after: 3 * time.Minute, near: 124, far: 11, state: checkCritical,
+			perfdata: []string{"crc_rate_near_end=20;~:1;~:10", "crc_rate_far_end=0;~:1;~:10"}},
+		// The counters were reset, no rate is computed.
+		{name: "reset", after: 4 * time.Minute, near: 5, far: 0, state: checkOK},
+		{name: "after reset", after: 5 * time.Minute, near: 5, far: 0, state: checkOK,
+			perfdata: []string{"crc_rate_near_end=0;~:1;~:10", "crc_rate_far_end=0;~:1;~:10"}},
+	} {
+		var r checkResult
+		status := vigorv5.Status{CrcNearEnd: tc.near, CrcFarEnd: tc.far}
+		if err := r.evaluateCRCRate(path, status, now.Add(tc.after), thresholds); err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if r.state != tc.state {
+			t.Errorf("%s: got state %d, want %d", tc.name, r.state, tc.state)
+		}
+		if !reflect.DeepEqual(r.perfdata, tc.perfdata) {
+			t.Errorf("%s: got perfdata %q, want %q", tc.name, r.perfdata, tc.perfdata)
+		}
+	}
+
+	state, err := readCheckState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.CrcNearEnd != 5 || state.CrcFarEnd != 0 || !state.Time.Equal(now.Add(5*time.Minute)) {
+		t.Errorf("got state %+v after the last check", state)
+	}
+}
+
+// errFetcher fails to fetch the DSL status.
+type errFetcher struct {
+	err error
+}
+
+func (f errFetcher) FetchStatus() (vigorv5.Status, error) {
+	return vigorv5.Status{}, f.err
+}
+
+func TestRunCheckFetchFailed(t *testing.T) {
+	for _, err := range []error{vigorv5.ErrLoginFailed, vigorv5.ErrParseFailed} {
+		if got := runCheck(errFetcher{err}, nil); got != checkUnknown {
+			t.Errorf("%v: got exit code %d, want %d", err, got, checkUnknown)
+		}
+	}
+	if got := runCheck(staticFetcher{}, nil); got != checkCritical {
+		t.Errorf("line down: got exit code %d, want %d", got, checkCritical)
+	}
+}
@@ -14,394 +14,237 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/SuperQ/draytek_exporter/config"
 	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 const namespace = "draytek"
 
-// Exporter collects Vigor stats from the given server and exports them using
-// the prometheus metrics package.
-type Exporter struct {
-	v *vigorv5.Vigor
+const (
+	defaultEnabled  = true
+	defaultDisabled = false
+)
+
+// ErrUnsupportedDevice is returned by a collector factory when the collector
+// does not support the device driver.
+var ErrUnsupportedDevice = errors.New("collector does not support the device driver")
+
+// ErrNotConfigured is returned by a collector factory when the collector has
+// nothing to collect.
+var ErrNotConfigured = errors.New("collector is not configured")
+
+// StatusFetcher is implemented by sources of DSL line status. All device
+// drivers implement it.
+type StatusFetcher interface {
+	FetchStatus() (vigorv5.Status, error)
 }
 
-// NewExporter returns an initialized Exporter.
-func NewExporter(v *vigorv5.Vigor) *Exporter {
-	return &Exporter{v: v}
+// Collector is the interface a collector has to implement.
+type Collector interface {
+	// Update gets new metrics and exposes them via the channel.
+	Update(ch chan<- prometheus.Metric) error
 }
 
+// collectorFactory creates a collector for the device. modules are the
+// custom modules from the config file.
+type collectorFactory func(device StatusFetcher, modules []config.Module, logger *slog.Logger) (Collector, error)
+
+var (
+	factories      = map[string]collectorFactory{}
+	collectorState = map[string]*bool{}
+)
+
 var (
 	draytekUpDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "", "up"),
 		"Was the draytek instance status successful?",
 		nil, nil,
 	)
-	draytekInfoDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "info"),
-		"Info about the draytek router",
-		[]string{"dsl_version", "mode", "profile"}, nil,
-	)
-
-	actualRateDownDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "downstream", "actual_bps"),
-		"The actual downstream bits per second rate",
-		nil, nil,
-	)
-	actualRateUpDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "upstream", "actual_bps"),
-		"The actual upstream bits per second rate",
-		nil, nil,
-	)
-	attainableRateDownDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "downstream", "attainable_bps"),
-		"The attainable downstream bits per second rate",
-		nil, nil,
-	)
-	attainableRateUpDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "upstream", "attainable_bps"),
-		"The attainable upstream bits per second rate",
-		nil, nil,
-	)
-	interleaveDepthDownDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "downstream", "interleave_depth"),
-		"The amount of interleaving configured for the downstream",
-		nil, nil,
-	)
-	interleaveDepthUpDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "upstream", "interleave_depth"),
-		"The amount of interleaving configured for the upstream",
-		nil, nil,
-	)
-	actualPsdDownDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "downstream", "actual_psd_db"),
-		"The actual downstream power spectrum density in dB",
-		nil, nil,
-	)
-	actualPsdUpDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "upstream", "actual_psd_db"),
-		"The actual upstream power spectrum density in dB",
-		nil, nil,
-	)
-	snrMarginDownDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "downstream", "snr_margin_db"),
-		"The downstream SNR margin in dB",
-		nil, nil,
-	)
-	snrMarginUpDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "upstream", "snr_margin_db"),
-		"The downstream SNR margin in dB",
-		nil, nil,
-	)
-
-	bitswapActiveNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "bitswap_active"),
-		"Whether bitswap is active on the near end",
-		nil, nil,
-	)
-	bitswapActiveFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "bitswap_active"),
-		"Whether bitswap is active on the far end",
-		nil, nil,
-	)
-	reTxActiveNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "retx_active"),
-		"Whether retransmission is active on the near end",
-		nil, nil,
-	)
-	reTxActiveFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "retx_active"),
-		"Whether retransmission is active on the far end",
-		nil, nil,
-	)
-	attenuationNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "attenuation_db"),
-		"The attenuation on the near end in dB",
-		nil, nil,
-	)
-	attenuationFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "attenuation_db"),
-		"The attenuation on the far end in dB",
-		nil, nil,
-	)
-	crcCountNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "crc_errors_total"),
-		"The number of CRC errors on the near end",
-		nil, nil,
-	)
-	crcCountFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "crc_errors_total"),
-		"The number of CRC errors on the far end",
-		nil, nil,
-	)
-	erroredSecondsNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "errored_seconds_total"),
-		"The number of seconds the near end was errored",
-		nil, nil,
-	)
-	erroredSecondsFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "errored_seconds_total"),
-		"The number of seconds the far end was errored",
-		nil, nil,
-	)
-	severelyErroredSecondsNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "severely_errored_seconds_total"),
-		"The number of seconds the near end was severely errored",
-		nil, nil,
-	)
-	severelyErroredSecondsFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "severely_errored_seconds_total"),
-		"The number of seconds the far end was severely errored",
-		nil, nil,
-	)
-	unavailableSecondsNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "unavailable_seconds_total"),
-		"The number of seconds the near end was unavailable",
-		nil, nil,
-	)
-	unavailableSecondsFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "unavailable_seconds_total"),
-		"The number of seconds the far end was unavailable",
-		nil, nil,
-	)
-	hecErrorCountNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "hec_errors_total"),
-		"The number of header errors on the near end",
-		nil, nil,
-	)
-	hecErrorCountFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "hec_errors_total"),
-		"The number of header errors on the far end",
-		nil, nil,
-	)
-	losFailureCountNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "los_failures_total"),
-		"The number of Loss of Signal failures at the near end",
-		nil, nil,
-	)
-	losFailureCountFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "los_failures_total"),
-		"The number of Loss of Signal failures at the far end",
-		nil, nil,
-	)
-	lofFailureCountNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "lof_failures_total"),
-		"The number of Loss of Frame failures at the near end",
-		nil, nil,
+	draytekDownInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "down_info"),
+		"The reason the draytek instance status was not successful",
+		[]string{"reason"}, nil,
 	)
-	lofFailureCountFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "lof_failures_total"),
-		"The number of Loss of Frame failures at the far end",
-		nil, nil,
-	)
-	lprFailureCountNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "lpr_failures_total"),
-		"The number of Loss of Power failures at the near end",
-		nil, nil,
-	)
-	lprFailureCountFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "lpr_failures_total"),
-		"The number of Loss of Power failures at the far end",
-		nil, nil,
-	)
-	lcdFailureCountNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "lcd_failures_total"),
-		"The number of Loss of Cell Delineation failures at the near end",
-		nil, nil,
-	)
-	lcdFailureCountFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "lcd_failures_total"),
-		"The number of Loss of Cell Delineation failures at the far end",
-		nil, nil,
+	scrapeDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collector_duration_seconds"),
+		"Duration of a collector scrape",
+		[]string{"collector"}, nil,
 	)
-	rfecCountNearEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "near_end", "reed_solomon_forward_error_corrections_total"),
-		"The number of Reed–Solomon Forward Error Corrections at the near end",
-		nil, nil,
-	)
-	rfecCountFarEndDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "far_end", "reed_solomon_forward_error_corrections_total"),
-		"The number of Reed–Solomon Forward Error Corrections at the far end",
-		nil, nil,
+	scrapeSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collector_success"),
+		"Whether a collector succeeded",
+		[]string{"collector"}, nil,
 	)
 )
 
-// Describe describes all the metrics ever exported by the draytek_exporter. It
-// implements prometheus.Collector.
-func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	ch <- draytekUpDesc
-	ch <- draytekInfoDesc
-	ch <- actualRateDownDesc
-	ch <- actualRateUpDesc
-	ch <- attainableRateDownDesc
-	ch <- attainableRateUpDesc
-	ch <- interleaveDepthDownDesc
-	ch <- interleaveDepthUpDesc
-	ch <- actualPsdDownDesc
-	ch <- actualPsdUpDesc
-	ch <- snrMarginDownDesc
-	ch <- snrMarginUpDesc
+func registerCollector(collector string, isDefaultEnabled bool, factory collectorFactory) {
+	var helpDefaultState string
+	if isDefaultEnabled {
+		helpDefaultState = "enabled"
+	} else {
+		helpDefaultState = "disabled"
+	}
+
+	flagName := fmt.Sprintf("collector.%s", collector)
+	flagHelp := fmt.Sprintf("Enable the %s collector (default: %s)", collector, helpDefaultState)
+	defaultValue := fmt.Sprintf("%v", isDefaultEnabled)
+
+	collectorState[collector] = kingpin.Flag(flagName, flagHelp).Default(defaultValue).Bool()
+	factories[collector] = factory
+}
+
+// DrayTekCollector implements the prometheus.Collector interface, running the
+// enabled collectors concurrently against a single device.
+type DrayTekCollector struct {
+	device StatusFetcher
+	login  *loginManager
+	logger *slog.Logger
 
-	ch <- bitswapActiveNearEndDesc
-	ch <- bitswapActiveFarEndDesc
-	ch <- reTxActiveNearEndDesc
-	ch <- reTxActiveFarEndDesc
-	ch <- attenuationNearEndDesc
-	ch <- attenuationFarEndDesc
-	ch <- crcCountNearEndDesc
-	ch <- crcCountFarEndDesc
-	ch <- erroredSecondsNearEndDesc
-	ch <- erroredSecondsFarEndDesc
-	ch <- severelyErroredSecondsNearEndDesc
-	ch <- severelyErroredSecondsFarEndDesc
-	ch <- unavailableSecondsNearEndDesc
-	ch <- unavailableSecondsFarEndDesc
-	ch <- hecErrorCountNearEndDesc
-	ch <- hecErrorCountFarEndDesc
-	ch <- losFailureCountNearEndDesc
-	ch <- losFailureCountFarEndDesc
-	ch <- lofFailureCountNearEndDesc
-	ch <- lofFailureCountFarEndDesc
-	ch <- lprFailureCountNearEndDesc
-	ch <- lprFailureCountFarEndDesc
-	ch <- lcdFailureCountNearEndDesc
-	ch <- lcdFailureCountFarEndDesc
-	ch <- rfecCountNearEndDesc
-	ch <- rfecCountFarEndDesc
+	mtx        sync.RWMutex
+	collectors map[string]Collector
 }
 
-// Collect fetches the stats from the draytek router and delivers them as
-// Prometheus metrics. It implements prometheus.Collector.
-func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	status, err := e.v.FetchStatus()
+// NewDrayTekCollector creates the enabled collectors that support the device.
+// Until login reports a successful login, the device is reported as down and
+// the collectors are not run. login may be nil for drivers that do not log in.
+func NewDrayTekCollector(device StatusFetcher, login *loginManager, modules []config.Module, logger *slog.Logger) (*DrayTekCollector, error) {
+	collectors, err := newCollectors(device, modules, logger)
 	if err != nil {
-		ch <- prometheus.MustNewConstMetric(
-			draytekUpDesc, prometheus.GaugeValue, 0.0,
-		)
+		return nil, err
+	}
+	return &DrayTekCollector{device: device, collectors: collectors, login: login, logger: logger}, nil
+}
+
+func newCollectors(device StatusFetcher, modules []config.Module, logger *slog.Logger) (map[string]Collector, error) {
+	collectors := map[string]Collector{}
+	for name, enabled := range collectorState {
+		if !*enabled {
+			continue
+		}
+		c, err := factories[name](device, modules, logger.With("collector", name))
+		if errors.Is(err, ErrUnsupportedDevice) {
+			logger.Debug("Collector does not support the device driver, skipping", "collector", name)
+			continue
+		}
+		if errors.Is(err, ErrNotConfigured) {
+			logger.Debug("Collector is not configured, skipping", "collector", name)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to create collector %s: %w", name, err)
+		}
+		collectors[name] = c
+	}
+	return collectors, nil
+}
+
+// prepareReload creates the collectors for modules, so that changes to the
+// config file take effect once they are passed to setCollectors. The session
+// on the device is kept.
+func (d *DrayTekCollector) prepareReload(modules []config.Module) (map[string]Collector, error) {
+	return newCollectors(d.device, modules, d.logger)
+}
+
+// setCollectors replaces the collectors run on each scrape.
+func (d *DrayTekCollector) setCollectors(collectors map[string]Collector) {
+	d.mtx.Lock()
+	d.collectors = collectors
+	d.mtx.Unlock()
+}
+
+// Describe implements the prometheus.Collector interface.
+func (d *DrayTekCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- draytekUpDesc
+	ch <- draytekDownInfoDesc
+	ch <- scrapeDurationDesc
+	ch <- scrapeSuccessDesc
+}
+
+// Collect implements the prometheus.Collector interface. The device is
+// reported as up if the login and at least one collector succeeded. Without
+// collectors, the login result decides, or for drivers that do not log in,
+// whether the device status can be fetched.
+func (d *DrayTekCollector) Collect(ch chan<- prometheus.Metric) {
+	err := d.login.acquire()
+	defer d.login.release()
+	if err != nil {
+		collectDown(ch, err)
 		return
 	}
-	ch <- prometheus.MustNewConstMetric(
-		draytekUpDesc, prometheus.GaugeValue, 1.0,
-	)
 
-	ch <- prometheus.MustNewConstMetric(
-		draytekInfoDesc, prometheus.GaugeValue, 1.0,
-		status.DSLVersion, status.Mode, status.Profile,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		actualRateDownDesc, prometheus.GaugeValue, float64(status.ActualRateDownstream),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		actualRateUpDesc, prometheus.GaugeValue, float64(status.ActualRateUpstream),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		attainableRateDownDesc, prometheus.GaugeValue, float64(status.AttainableRateDownstream),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		attainableRateUpDesc, prometheus.GaugeValue, float64(status.AttainableRateUpstream),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		interleaveDepthDownDesc, prometheus.GaugeValue, float64(status.InterleaveDepthDownstream),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		interleaveDepthUpDesc, prometheus.GaugeValue, float64(status.InterleaveDepthUpstream),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		actualPsdDownDesc, prometheus.GaugeValue, status.ActualPSDDownstream,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		actualPsdUpDesc, prometheus.GaugeValue, status.ActualPSDUpstream,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		snrMarginDownDesc, prometheus.GaugeValue, status.SNRMarginDownstream,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		snrMarginUpDesc, prometheus.GaugeValue, status.SNRMarginUpstream,
-	)
+	d.mtx.RLock()
+	collectors := d.collectors
+	d.mtx.RUnlock()
 
+	if len(collectors) == 0 && d.login == nil {
+		if _, err := d.device.FetchStatus(); err != nil {
+			d.logger.Error("Unable to fetch the device status", "err", err)
+			collectDown(ch, err)
+			return
+		}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		errMtx sync.Mutex
+		errs   []error
+	)
+	for name, c := range collectors {
+		wg.Go(func() {
+			if err := execute(name, c, ch, d.logger); err != nil {
+				errMtx.Lock()
+				errs = append(errs, err)
+				errMtx.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	if len(collectors) > 0 && len(errs) == len(collectors) {
+		err := errors.Join(errs...)
+		d.login.scraped(err)
+		collectDown(ch, err)
+		return
+	}
+	d.login.scraped(nil)
 	ch <- prometheus.MustNewConstMetric(
-		bitswapActiveNearEndDesc, prometheus.GaugeValue, optionToFloat64(status.BitswapNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		bitswapActiveFarEndDesc, prometheus.GaugeValue, optionToFloat64(status.BitswapFarEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		reTxActiveNearEndDesc, prometheus.GaugeValue, optionToFloat64(status.ReTxNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		reTxActiveFarEndDesc, prometheus.GaugeValue, optionToFloat64(status.ReTxFarEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		attenuationNearEndDesc, prometheus.GaugeValue, status.AttenuationNearEnd,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		attenuationFarEndDesc, prometheus.GaugeValue, status.AttenuationFarEnd,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		crcCountNearEndDesc, prometheus.CounterValue, float64(status.CrcNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		crcCountFarEndDesc, prometheus.CounterValue, float64(status.CrcFarEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		erroredSecondsNearEndDesc, prometheus.CounterValue, float64(status.EsNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		erroredSecondsFarEndDesc, prometheus.CounterValue, float64(status.EsFarEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		severelyErroredSecondsNearEndDesc, prometheus.CounterValue, float64(status.SesNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		severelyErroredSecondsFarEndDesc, prometheus.CounterValue, float64(status.SesFarEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		unavailableSecondsNearEndDesc, prometheus.CounterValue, float64(status.UasNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		unavailableSecondsFarEndDesc, prometheus.CounterValue, float64(status.UasFarEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		hecErrorCountNearEndDesc, prometheus.CounterValue, float64(status.HecErrorsNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		hecErrorCountFarEndDesc, prometheus.CounterValue, float64(status.HecErrorsFarEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		losFailureCountNearEndDesc, prometheus.CounterValue, float64(status.LosFailureNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		losFailureCountFarEndDesc, prometheus.CounterValue, float64(status.LosFailureFarEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		lofFailureCountNearEndDesc, prometheus.CounterValue, float64(status.LofFailureNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		lofFailureCountFarEndDesc, prometheus.CounterValue, float64(status.LofFailureFarEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		lprFailureCountNearEndDesc, prometheus.CounterValue, float64(status.LprFailureNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		lprFailureCountFarEndDesc, prometheus.CounterValue, float64(status.LprFailureFarEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		lcdFailureCountNearEndDesc, prometheus.CounterValue, float64(status.LcdFailureNearEnd),
-	)
-	ch <- prometheus.MustNewConstMetric(
-		lcdFailureCountFarEndDesc, prometheus.CounterValue, float64(status.LcdFailureFarEnd),
+		draytekUpDesc, prometheus.GaugeValue, 1.0,
 	)
+}
+
+// collectDown reports the device as down, along with the reason.
+func collectDown(ch chan<- prometheus.Metric, err error) {
 	ch <- prometheus.MustNewConstMetric(
-		rfecCountNearEndDesc, prometheus.CounterValue, float64(status.RfecNearEnd),
+		draytekUpDesc, prometheus.GaugeValue, 0.0,
 	)
 	ch <- prometheus.MustNewConstMetric(
-		rfecCountFarEndDesc, prometheus.CounterValue, float64(status.RfecFarEnd),
+		draytekDownInfoDesc, prometheus.GaugeValue, 1.0,
+		downReason(err),
 	)
 }
 
-func optionToFloat64(option bool) float64 {
-	if option {
-		return 1
+func execute(name string, c Collector, ch chan<- prometheus.Metric, logger *slog.Logger) error {
+	begin := time.Now()
+	err := c.Update(ch)
+	duration := time.Since(begin)
+	var success float64
+
+	if err != nil {
+		logger.Error("collector failed", "name", name, "duration_seconds", duration.Seconds(), "err", err)
+		success = 0
+	} else {
+		logger.Debug("collector succeeded", "name", name, "duration_seconds", duration.Seconds())
+		success = 1
 	}
-	return 0
+	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name)
+	return err
 }
@@ -0,0 +1,174 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// updateCollector adapts a Collector to a prometheus.Collector for testutil.
+type updateCollector struct {
+	c Collector
+}
+
+func (u updateCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (u updateCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = u.c.Update(ch)
+}
+
+// replayVigor returns a Vigor v5 client that is served the exchanges recorded
+// in dir.
+func replayVigor(t *testing.T, dir string) *vigorv5.Vigor {
+	t.Helper()
+	rt, err := vigorv5.NewReplayTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := vigorv5.New(slog.New(slog.DiscardHandler), "192.0.2.1", "monitor", "secret", vigorv5.WithTransport(rt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Login(); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// collectorFunc adapts a function to a Collector.
+type collectorFunc func(ch chan<- prometheus.Metric) error
+
+func (f collectorFunc) Update(ch chan<- prometheus.Metric) error {
+	return f(ch)
+}
+
+func TestDrayTekCollectorUp(t *testing.T) {
+	ok := collectorFunc(func(ch chan<- prometheus.Metric) error { return nil })
+	failed := collectorFunc(func(ch chan<- prometheus.Metric) error { return errors.New("unexpected response") })
+
+	for _, tc := range []struct {
+		name       string
+		collectors map[string]Collector
+		want       string
+	}{
+		{
+			name:       "without dsl collector",
+			collectors: map[string]Collector{"wireless": ok},
+			want: `
+# HELP draytek_up Was the draytek instance status successful?
+# TYPE draytek_up gauge
+draytek_up 1
+`,
+		},
+		{
+			name:       "one collector failed",
+			collectors: map[string]Collector{"dsl": failed, "wireless": ok},
+			want: `
+# HELP draytek_up Was the draytek instance status successful?
+# TYPE draytek_up gauge
+draytek_up 1
+`,
+		},
+		{
+			name:       "all collectors failed",
+			collectors: map[string]Collector{"dsl": failed, "wireless": failed},
+			want: `
+# HELP draytek_down_info The reason the draytek instance status was not successful
+# TYPE draytek_down_info gauge
+draytek_down_info{reason="fetch_failed"} 1
+# HELP draytek_up Was the draytek instance status successful?
+# TYPE draytek_up gauge
+draytek_up 0
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &DrayTekCollector{collectors: tc.collectors, logger: slog.New(slog.DiscardHandler)}
+			if err := testutil.CollectAndCompare(d, strings.NewReader(tc.want), "draytek_up", "draytek_down_info"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDrayTekCollectorUpWithoutCollectors(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	loggedIn := newLoginManager(fakeLoginer{}, logger, false)
+	loggedIn.setLoggedIn()
+	loginFailed := newLoginManager(fakeLoginer{}, logger, false)
+	loginFailed.setErr(vigorv5.ErrLoginFailed)
+
+	up := `
+# HELP draytek_up Was the draytek instance status successful?
+# TYPE draytek_up gauge
+draytek_up 1
+`
+	for _, tc := range []struct {
+		name   string
+		device StatusFetcher
+		login  *loginManager
+		want   string
+	}{
+		{
+			name:   "logged in",
+			device: errFetcher{errors.New("not fetched")},
+			login:  loggedIn,
+			want:   up,
+		},
+		{
+			name:   "login failed",
+			device: staticFetcher{},
+			login:  loginFailed,
+			want: `
+# HELP draytek_down_info The reason the draytek instance status was not successful
+# TYPE draytek_down_info gauge
+draytek_down_info{reason="login_failed"} 1
+# HELP draytek_up Was the draytek instance status successful?
+# TYPE draytek_up gauge
+draytek_up 0
+`,
+		},
+		{
+			name:   "status fetched",
+			device: staticFetcher{},
+			want:   up,
+		},
+		{
+			name:   "status failed",
+			device: errFetcher{errors.New("unexpected response")},
+			want: `
+# HELP draytek_down_info The reason the draytek instance status was not successful
+# TYPE draytek_down_info gauge
+draytek_down_info{reason="fetch_failed"} 1
+# HELP draytek_up Was the draytek instance status successful?
+# TYPE draytek_up gauge
+draytek_up 0
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &DrayTekCollector{device: tc.device, login: tc.login, collectors: map[string]Collector{}, logger: logger}
+			if err := testutil.CollectAndCompare(d, strings.NewReader(tc.want), "draytek_up", "draytek_down_info"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package config loads the exporter configuration file.
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/SuperQ/draytek_exporter/detect"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"go.yaml.in/yaml/v2"
+)
+
+const (
+	defaultOp     = "501"
+	defaultType   = "gauge"
+	defaultParser = "float"
+
+	defaultDriver   = detect.APIVigorV5
+	defaultUsername = "monitor"
+)
+
+// DriverAuto detects the API of the target when it is added.
+const DriverAuto = "auto"
+
+var (
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// multiPathRE matches gjson paths that select all elements of an array,
+	// with `#.` or a `#(...)#` query.
+	multiPathRE = regexp.MustCompile(`(^|\.)#\.|\)#`)
+)
+
+// Config is the contents of the configuration file.
+type Config struct {
+	Targets []Target `yaml:"targets"`
+	Modules []Module `yaml:"modules"`
+}
+
+// Target is a device scraped through the /probe endpoint.
+type Target struct {
+	// Name selects the target in the target URL parameter.
+	Name    string `yaml:"name"`
+	Address string `yaml:"address"`
+	// Driver is vigor_v5, drayos_rest or auto, defaults to vigor_v5.
+	Driver string `yaml:"driver"`
+	// Username defaults to monitor.
+	Username string `yaml:"username"`
+	// PasswordFile is re-read when it changes, so rotated passwords are used
+	// on the next login.
+	PasswordFile string `yaml:"password_file"`
+	// CredentialHelper is a command that prints the credentials, it is run
+	// with the target name as the last argument. If neither PasswordFile nor
+	// CredentialHelper are set, the --credential-helper flag is used.
+	CredentialHelper []string `yaml:"credential_helper"`
+}
+
+// Module describes a webproc.cgi request and the metrics extracted from its
+// response.
+type Module struct {
+	Name string `yaml:"name"`
+	PID  string `yaml:"pid"`
+	// Op defaults to 501, which reads a page.
+	Op string `yaml:"op"`
+	// CT defaults to requesting the pid table only.
+	CT      string   `yaml:"ct"`
+	Metrics []Metric `yaml:"metrics"`
+}
+
+// Metric maps a value in the response JSON to a metric.
+type Metric struct {
+	// Name is exported with the draytek_custom_ prefix, so that it can not
+	// collide with the metrics of the built-in collectors.
+	Name string `yaml:"name"`
+	Help string `yaml:"help"`
+	// Type is either gauge or counter.
+	Type string `yaml:"type"`
+	// Path is a gjson path into the response. If it selects an array, one
+	// metric is exported per element, and Labels must tell them apart.
+	Path string `yaml:"path"`
+	// Value is a gjson path to the value, relative to each selected element.
+	// If empty, the element itself is the value.
+	Value string `yaml:"value"`
+	// Parser is the name of the parser in vigorv5.Parsers used to convert the
+	// value.
+	Parser string `yaml:"parser"`
+	// Labels maps label names to gjson paths relative to each selected
+	// element.
+	Labels map[string]string `yaml:"labels"`
+	// ConstLabels are added to every metric as is.
+	ConstLabels map[string]string `yaml:"const_labels"`
+}
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// validate checks the config and fills in defaults.
+func (c *Config) validate() error {
+	targets := map[string]bool{}
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if t.Name == "" {
+			return fmt.Errorf("target %d has no name", i)
+		}
+		if targets[t.Name] {
+			return fmt.Errorf("duplicate target %q", t.Name)
+		}
+		targets[t.Name] = true
+		if t.Address == "" {
+			return fmt.Errorf("target %q has no address", t.Name)
+		}
+		if t.Driver == "" {
+			t.Driver = defaultDriver
+		}
+		if t.Driver != detect.APIVigorV5 && t.Driver != detect.APIDrayOSREST && t.Driver != DriverAuto {
+			return fmt.Errorf("target %q has unsupported driver %q", t.Name, t.Driver)
+		}
+		if t.Username == "" {
+			t.Username = defaultUsername
+		}
+		if t.PasswordFile != "" && len(t.CredentialHelper) > 0 {
+			return fmt.Errorf("target %q has both password_file and credential_helper", t.Name)
+		}
+	}
+
+	modules := map[string]bool{}
+	metrics := map[string]bool{}
+	for i := range c.Modules {
+		m := &c.Modules[i]
+		if m.Name == "" {
+			return fmt.Errorf("module %d has no name", i)
+		}
+		if modules[m.Name] {
+			return fmt.Errorf("duplicate module %q", m.Name)
+		}
+		modules[m.Name] = true
+		if m.PID == "" {
+			return fmt.Errorf("module %q has no pid", m.Name)
+		}
+		if m.Op == "" {
+			m.Op = defaultOp
+		}
+		if m.CT == "" {
+			m.CT = fmt.Sprintf(`{"param":[],"ct":[{"%s":[]}]}`, m.PID)
+		}
+
+		for j := range m.Metrics {
+			metric := &m.Metrics[j]
+			if !metricNameRE.MatchString(metric.Name) {
+				return fmt.Errorf("module %q has invalid metric name %q", m.Name, metric.Name)
+			}
+			if metrics[metric.Name] {
+				return fmt.Errorf("duplicate metric %q", metric.Name)
+			}
+			metrics[metric.Name] = true
+			if metric.Path == "" {
+				return fmt.Errorf("metric %q has no path", metric.Name)
+			}
+			if multiPathRE.MatchString(metric.Path) && len(metric.Labels) == 0 {
+				return fmt.Errorf("metric %q selects several elements but has no labels to tell them apart", metric.Name)
+			}
+			if metric.Help == "" {
+				metric.Help = fmt.Sprintf("Value of %s from %s", metric.Path, m.PID)
+			}
+			if metric.Type == "" {
+				metric.Type = defaultType
+			}
+			if metric.Type != "gauge" && metric.Type != "counter" {
+				return fmt.Errorf("metric %q has invalid type %q", metric.Name, metric.Type)
+			}
+			if metric.Parser == "" {
+				metric.Parser = defaultParser
+			}
+			if _, ok := vigorv5.Parsers[metric.Parser]; !ok {
+				return fmt.Errorf("metric %q has unknown parser %q", metric.Name, metric.Parser)
+			}
+			for name := range metric.Labels {
+				if !labelNameRE.MatchString(name) {
+					return fmt.Errorf("metric %q has invalid label name %q", metric.Name, name)
+				}
+			}
+			for name := range metric.ConstLabels {
+				if !labelNameRE.MatchString(name) {
+					return fmt.Errorf("metric %q has invalid label name %q", metric.Name, name)
+				}
+				if _, ok := metric.Labels[name]; ok {
+					return fmt.Errorf("metric %q has duplicate label %q", metric.Name, name)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v2"
+)
+
+func TestValidateMetrics(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		metrics string
+		err     string
+	}{
+		{
+			name: "single element query",
+			metrics: `
+      - name: dsl_trellis
+        path: 'ct.0.0MONITORING_DSL_GENERAL.#(Name=="Setting").Trellis'`,
+		},
+		{
+			name: "all elements with labels",
+			metrics: `
+      - name: ssid_clients
+        path: 'ct.0.0MONITORING_WIRELESS_GENERAL.#(Name=="SSID")#'
+        value: Clients
+        labels:
+          ssid: SSID`,
+		},
+		{
+			name: "all elements query without labels",
+			metrics: `
+      - name: ssid_clients
+        path: 'ct.0.0MONITORING_WIRELESS_GENERAL.#(Name=="SSID")#.Clients'`,
+			err: "has no labels",
+		},
+		{
+			name: "all elements path without labels",
+			metrics: `
+      - name: ssid_clients
+        path: 'ct.0.0MONITORING_WIRELESS_GENERAL.#.Clients'`,
+			err: "has no labels",
+		},
+		{
+			name: "duplicate name",
+			metrics: `
+      - name: dsl_trellis
+        path: 'ct.0.Trellis'
+      - name: dsl_trellis
+        path: 'ct.0.Bitswap'`,
+			err: "duplicate metric",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Config{}
+			b := "modules:\n  - name: test\n    pid: 0TEST\n    metrics:" + tc.metrics + "\n"
+			if err := yaml.UnmarshalStrict([]byte(b), c); err != nil {
+				t.Fatal(err)
+			}
+			err := c.validate()
+			switch {
+			case tc.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+				t.Errorf("got error %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
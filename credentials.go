@@ -0,0 +1,211 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	"github.com/alecthomas/kingpin/v2"
+)
+
+const credentialHelperTimeout = 30 * time.Second
+
+var (
+	passwordFilePath        = kingpin.Flag("password-file", "File that contains the password to authenticate to the target, re-read when it changes. Takes precedence over --password-env").Default("").String()
+	credentialHelperCommand = kingpin.Flag("credential-helper", "Command that prints the credentials to authenticate to the target, run with the target as the last argument. Split into arguments on whitespace, quoting is not supported. Takes precedence over --password-file").Default("").String()
+	credentialHelperTTL     = kingpin.Flag("credential-helper.ttl", "How long to cache the credentials printed by the credential helper").Default("5m").Duration()
+)
+
+var errMissingPassword = errors.New("missing password")
+
+// credentialsFunc is the signature of the drivers' WithCredentials option.
+type credentialsFunc = func(refresh bool) (username string, password string, err error)
+
+// flagCredentials returns the credentials for target from the credential
+// helper or password file flags, or nil if neither is set.
+func flagCredentials(target string, username string) credentialsFunc {
+	switch {
+	case *credentialHelperCommand != "":
+		return newCredentialHelper(strings.Fields(*credentialHelperCommand), target, *credentialHelperTTL).credentials(username)
+	case *passwordFilePath != "":
+		return newPasswordFile(*passwordFilePath).credentials(username)
+	}
+	return nil
+}
+
+// targetCredentials returns the credentials for a target from the config
+// file, falling back to the credential helper flag. It returns nil if the
+// target has no credentials.
+func targetCredentials(t config.Target) credentialsFunc {
+	switch {
+	case t.PasswordFile != "":
+		return newPasswordFile(t.PasswordFile).credentials(t.Username)
+	case len(t.CredentialHelper) > 0:
+		return newCredentialHelper(t.CredentialHelper, t.Name, *credentialHelperTTL).credentials(t.Username)
+	case *credentialHelperCommand != "":
+		return newCredentialHelper(strings.Fields(*credentialHelperCommand), t.Name, *credentialHelperTTL).credentials(t.Username)
+	}
+	return nil
+}
+
+// loadCredentials returns the credentials for target once, from the
+// credential helper or password file flags, otherwise from the passwordEnv
+// env var.
+func loadCredentials(target string, username string, passwordEnv string) (string, string, error) {
+	if credentials := flagCredentials(target, username); credentials != nil {
+		return credentials(false)
+	}
+	password := os.Getenv(passwordEnv)
+	if password == "" {
+		return "", "", fmt.Errorf("%w: env var %s is not set", errMissingPassword, passwordEnv)
+	}
+	return username, password, nil
+}
+
+// passwordFile reads a password from a file. The file is re-read when its
+// modification time or size changes, so rotated Kubernetes or Vault agent
+// secrets are used on the next login without restarting the exporter.
+type passwordFile struct {
+	path string
+
+	mtx      sync.Mutex
+	modTime  time.Time
+	size     int64
+	password string
+}
+
+func newPasswordFile(path string) *passwordFile {
+	return &passwordFile{path: path}
+}
+
+// Password returns the contents of the file without trailing newlines.
+func (p *passwordFile) Password() (string, error) {
+	// Stat follows symlinks, so Kubernetes secret updates, which swap a
+	// symlink, are noticed.
+	fi, err := os.Stat(p.path)
+	if err != nil {
+		return "", err
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.password != "" && fi.ModTime().Equal(p.modTime) && fi.Size() == p.size {
+		return p.password, nil
+	}
+
+	b, err := os.ReadFile(p.path)
+	if err != nil {
+		return "", err
+	}
+	password := strings.TrimRight(string(b), "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("%w: %s is empty", errMissingPassword, p.path)
+	}
+	p.modTime = fi.ModTime()
+	p.size = fi.Size()
+	p.password = password
+	return password, nil
+}
+
+// credentials returns a function for the drivers' WithCredentials option
+// that logs in as username with the current password from the file.
+func (p *passwordFile) credentials(username string) credentialsFunc {
+	return func(bool) (string, string, error) {
+		password, err := p.Password()
+		return username, password, err
+	}
+}
+
+// credentialHelper runs an external command, such as the CLI of a secret
+// manager, that prints the credentials as "username=<username>" and
+// "password=<password>" lines. The output is cached for ttl, and the command
+// is run again when the device rejects the credentials.
+type credentialHelper struct {
+	command []string
+	ttl     time.Duration
+
+	mtx      sync.Mutex
+	username string
+	password string
+	expires  time.Time
+}
+
+// newCredentialHelper returns a credentialHelper that runs command with
+// target appended.
+func newCredentialHelper(command []string, target string, ttl time.Duration) *credentialHelper {
+	return &credentialHelper{
+		command: append(slices.Clone(command), target),
+		ttl:     ttl,
+	}
+}
+
+// credentials returns a function for the drivers' WithCredentials option.
+// username is used if the command does not print one.
+func (h *credentialHelper) credentials(username string) credentialsFunc {
+	return func(refresh bool) (string, string, error) {
+		h.mtx.Lock()
+		defer h.mtx.Unlock()
+		if refresh || time.Now().After(h.expires) {
+			if err := h.run(); err != nil {
+				return "", "", err
+			}
+		}
+		if h.username != "" {
+			return h.username, h.password, nil
+		}
+		return username, h.password, nil
+	}
+}
+
+func (h *credentialHelper) run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, h.command[0], h.command[1:]...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return fmt.Errorf("credential helper %s failed: %w: %s", h.command[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return fmt.Errorf("credential helper %s failed: %w", h.command[0], err)
+	}
+
+	var username, password string
+	for line := range strings.Lines(string(out)) {
+		key, value, _ := strings.Cut(strings.TrimRight(line, "\r\n"), "=")
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+	if password == "" {
+		return fmt.Errorf("%w: credential helper %s printed no password", errMissingPassword, h.command[0])
+	}
+
+	h.username = username
+	h.password = password
+	h.expires = time.Now().Add(h.ttl)
+	return nil
+}
@@ -0,0 +1,337 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	"github.com/SuperQ/draytek_exporter/drayos"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+)
+
+// loginServer is a DrayOS REST API stand-in that records the credentials of
+// each login and accepts them all.
+type loginServer struct {
+	*httptest.Server
+
+	mtx    sync.Mutex
+	logins []string
+}
+
+func newLoginServer(t *testing.T) *loginServer {
+	t.Helper()
+	s := &loginServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/login" {
+			http.NotFound(w, r)
+			return
+		}
+		var login struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mtx.Lock()
+		s.logins = append(s.logins, login.Username+":"+login.Password)
+		s.mtx.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"token":"test-token"}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// lastLogin returns the credentials of the last login, as "username:password".
+func (s *loginServer) lastLogin() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if len(s.logins) == 0 {
+		return ""
+	}
+	return s.logins[len(s.logins)-1]
+}
+
+// writePassword replaces the password file and moves its modification time
+// forward, so the change is noticed even on coarse file system timestamps.
+func writePassword(t *testing.T, path string, content string, age time.Duration) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPasswordFileTrim(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    string
+		err     error
+	}{
+		{name: "newline", content: "secret\n", want: "secret"},
+		{name: "crlf", content: "secret\r\n", want: "secret"},
+		{name: "blank lines", content: "secret\n\n\n", want: "secret"},
+		{name: "no newline", content: "secret", want: "secret"},
+		{name: "spaces", content: " secret \n", want: " secret "},
+		{name: "empty", content: "\n", err: errMissingPassword},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "password")
+			writePassword(t, path, tc.content, 0)
+			got, err := newPasswordFile(path).Password()
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := newPasswordFile(filepath.Join(t.TempDir(), "missing")).Password(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v for a missing file, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestPasswordFileRotation(t *testing.T) {
+	s := newLoginServer(t)
+	path := filepath.Join(t.TempDir(), "password")
+	writePassword(t, path, "before\n", time.Hour)
+
+	c, err := drayos.New(slog.New(slog.DiscardHandler), s.URL, "monitor", "", false,
+		drayos.WithCredentials(newPasswordFile(path).credentials("monitor")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Login(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.lastLogin(); got != "monitor:before" {
+		t.Errorf("got login %q, want monitor:before", got)
+	}
+
+	// The rotated password has the same size, only the modification time
+	// tells the versions apart.
+	writePassword(t, path, "after!\n", 0)
+	if err := c.Login(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.lastLogin(); got != "monitor:after!" {
+		t.Errorf("got login %q after the rotation, want monitor:after!", got)
+	}
+}
+
+func TestTargetPasswordFile(t *testing.T) {
+	defer func(scheme string) {
+		*drayosScheme = scheme
+	}(*drayosScheme)
+	*drayosScheme = "http"
+
+	s := newLoginServer(t)
+	path := filepath.Join(t.TempDir(), "password")
+	writePassword(t, path, "office-secret\n", time.Hour)
+
+	logger := slog.New(slog.DiscardHandler)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := newTargetManager(logger, nil)
+	defer m.stop()
+	err := m.update(ctx, []config.Target{{
+		Name:         "office",
+		Address:      strings.TrimPrefix(s.URL, "http://"),
+		Driver:       "drayos_rest",
+		Username:     "admin",
+		PasswordFile: path,
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.lastLogin() == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("target did not log in")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := s.lastLogin(); got != "admin:office-secret" {
+		t.Errorf("got login %q, want admin:office-secret", got)
+	}
+}
+
+// writeHelper writes a credential helper script that appends its arguments to
+// a log file and runs body. It returns the script and the log file.
+func writeHelper(t *testing.T, body string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	script, calls := filepath.Join(dir, "helper.sh"), filepath.Join(dir, "calls")
+	content := "#!/bin/sh\necho \"$@\" >> " + calls + "\n" + body + "\n"
+	if err := os.WriteFile(script, []byte(content), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return script, calls
+}
+
+// helperCalls returns the number of times the helper script ran.
+func helperCalls(t *testing.T, calls string) int {
+	t.Helper()
+	b, err := os.ReadFile(calls)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(b), "\n")
+}
+
+func TestCredentialHelperCache(t *testing.T) {
+	script, calls := writeHelper(t, `printf 'username=admin\npassword=secret\n'`)
+
+	for _, tc := range []struct {
+		name    string
+		ttl     time.Duration
+		refresh []bool
+		want    int
+	}{
+		{name: "cached", ttl: time.Hour, refresh: []bool{false, false, false}, want: 1},
+		{name: "expired", ttl: 0, refresh: []bool{false, false, false}, want: 3},
+		{name: "refresh", ttl: time.Hour, refresh: []bool{false, true, false, true}, want: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Remove(calls)
+			credentials := newCredentialHelper([]string{script, "--field"}, "office", tc.ttl).credentials("monitor")
+			for _, refresh := range tc.refresh {
+				username, password, err := credentials(refresh)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if username != "admin" || password != "secret" {
+					t.Errorf("got %q %q, want admin secret", username, password)
+				}
+			}
+			if got := helperCalls(t, calls); got != tc.want {
+				t.Errorf("helper ran %d times, want %d", got, tc.want)
+			}
+			b, err := os.ReadFile(calls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if args, _, _ := strings.Cut(string(b), "\n"); args != "--field office" {
+				t.Errorf("helper got arguments %q, want the target appended", args)
+			}
+		})
+	}
+}
+
+func TestCredentialHelperDefaultUsername(t *testing.T) {
+	script, _ := writeHelper(t, `echo password=secret`)
+	username, password, err := newCredentialHelper([]string{script}, "office", time.Hour).credentials("monitor")(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "monitor" || password != "secret" {
+		t.Errorf("got %q %q, want monitor secret", username, password)
+	}
+}
+
+func TestCredentialHelperFailed(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "exit status", body: "echo vault is sealed >&2\nexit 1", want: "vault is sealed"},
+		{name: "no password", body: "echo username=admin", want: "printed no password"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			script, _ := writeHelper(t, tc.body)
+			s := newLoginServer(t)
+			c, err := drayos.New(slog.New(slog.DiscardHandler), s.URL, "monitor", "", false,
+				drayos.WithCredentials(newCredentialHelper([]string{script}, "office", time.Hour).credentials("monitor")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.Login()
+			if !errors.Is(err, drayos.ErrCredentialsFailed) {
+				t.Fatalf("got error %v, want %v", err, drayos.ErrCredentialsFailed)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got error %q, want it to contain %q", err, tc.want)
+			}
+			if got := downReason(err); got != "credentials_failed" {
+				t.Errorf("got down reason %q, want credentials_failed", got)
+			}
+			if got := s.lastLogin(); got != "" {
+				t.Errorf("got login %q without credentials", got)
+			}
+		})
+	}
+}
+
+// TestCredentialHelperRefreshOncePerRequest checks that a request with
+// rejected credentials runs the helper at most once.
+func TestCredentialHelperRefreshOncePerRequest(t *testing.T) {
+	script, calls := writeHelper(t, `echo password=wrong`)
+
+	// The router rejects every login, requests without a session are
+	// unauthorized.
+	router := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("op") != "552" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(vigorv5.Encode(`{"rid":"0001","ct":[]}`)))
+	}))
+	defer router.Close()
+
+	v, err := vigorv5.New(slog.New(slog.DiscardHandler), strings.TrimPrefix(router.URL, "http://"), "monitor", "",
+		vigorv5.WithCredentials(newCredentialHelper([]string{script}, "office", time.Hour).credentials("monitor")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 2 {
+		if _, err := v.FetchStatus(); !errors.Is(err, vigorv5.ErrLoginFailed) {
+			t.Fatalf("request %d: got error %v, want %v", i, err, vigorv5.ErrLoginFailed)
+		}
+		// The first login uses the helper output, every following request
+		// refreshes it once.
+		if got := helperCalls(t, calls); got != i+1 {
+			t.Errorf("request %d: helper ran %d times, want %d", i, got, i+1)
+		}
+	}
+}
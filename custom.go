@@ -0,0 +1,166 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tidwall/gjson"
+)
+
+var (
+	customModulesMtx sync.RWMutex
+	// customModules are the modules from the config file. They are replaced
+	// on config reloads, use getCustomModules and setCustomModules.
+	customModules []config.Module
+)
+
+func getCustomModules() []config.Module {
+	customModulesMtx.RLock()
+	defer customModulesMtx.RUnlock()
+	return customModules
+}
+
+func setCustomModules(modules []config.Module) {
+	customModulesMtx.Lock()
+	defer customModulesMtx.Unlock()
+	customModules = modules
+}
+
+func init() {
+	registerCollector("custom", defaultEnabled, NewCustomCollector)
+}
+
+// CustomCollector collects the metrics defined by the modules in the config
+// file.
+type CustomCollector struct {
+	v       *vigorv5.Vigor
+	logger  *slog.Logger
+	modules []customModule
+}
+
+type customModule struct {
+	config.Module
+	metrics []customMetric
+}
+
+type customMetric struct {
+	config.Metric
+	desc       *prometheus.Desc
+	valueType  prometheus.ValueType
+	labelNames []string
+	parse      func(string) float64
+}
+
+// NewCustomCollector returns an initialized CustomCollector.
+func NewCustomCollector(device StatusFetcher, modules []config.Module, logger *slog.Logger) (Collector, error) {
+	v, ok := device.(*vigorv5.Vigor)
+	if !ok {
+		return nil, ErrUnsupportedDevice
+	}
+	if len(modules) == 0 {
+		return nil, ErrNotConfigured
+	}
+
+	c := &CustomCollector{v: v, logger: logger}
+	for _, m := range modules {
+		module := customModule{Module: m}
+		for _, metric := range m.Metrics {
+			labelNames := slices.Sorted(maps.Keys(metric.Labels))
+			valueType := prometheus.GaugeValue
+			if metric.Type == "counter" {
+				valueType = prometheus.CounterValue
+			}
+			module.metrics = append(module.metrics, customMetric{
+				Metric:     metric,
+				desc:       prometheus.NewDesc(prometheus.BuildFQName(namespace, "custom", metric.Name), metric.Help, labelNames, metric.ConstLabels),
+				valueType:  valueType,
+				labelNames: labelNames,
+				parse:      vigorv5.Parsers[metric.Parser],
+			})
+		}
+		c.modules = append(c.modules, module)
+	}
+	return c, nil
+}
+
+// Update fetches the pages of all modules and delivers the mapped values as
+// Prometheus metrics.
+func (c *CustomCollector) Update(ch chan<- prometheus.Metric) error {
+	var errs []error
+	for _, m := range c.modules {
+		resp, err := c.v.Fetch(m.PID, m.Op, m.CT)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("module %s: %w", m.Name, err))
+			continue
+		}
+		for _, metric := range m.metrics {
+			result := gjson.Get(resp, metric.Path)
+			if !result.Exists() {
+				c.logger.Debug("Path not found in response", "module", m.Name, "metric", metric.Name, "path", metric.Path)
+				continue
+			}
+			if !result.IsArray() {
+				metric.collect(ch, result, metric.labelValues(result))
+				continue
+			}
+			if len(metric.labelNames) == 0 {
+				errs = append(errs, fmt.Errorf("module %s: metric %s: path selects an array but the metric has no labels", m.Name, metric.Name))
+				continue
+			}
+			// Elements with the same label values would be duplicate series,
+			// which fail the whole scrape.
+			seen := map[string]bool{}
+			for _, element := range result.Array() {
+				labelValues := metric.labelValues(element)
+				key := strings.Join(labelValues, "\xff")
+				if seen[key] {
+					errs = append(errs, fmt.Errorf("module %s: metric %s: duplicate labels %v", m.Name, metric.Name, labelValues))
+					continue
+				}
+				seen[key] = true
+				metric.collect(ch, element, labelValues)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m customMetric) labelValues(element gjson.Result) []string {
+	labelValues := make([]string, 0, len(m.labelNames))
+	for _, name := range m.labelNames {
+		labelValues = append(labelValues, element.Get(m.Labels[name]).String())
+	}
+	return labelValues
+}
+
+func (m customMetric) collect(ch chan<- prometheus.Metric, element gjson.Result, labelValues []string) {
+	value := element
+	if m.Value != "" {
+		value = element.Get(m.Value)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		m.desc, m.valueType, m.parse(value.String()),
+		labelValues...,
+	)
+}
@@ -0,0 +1,82 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const customConfig = `
+modules:
+  - name: dsl_extra
+    pid: 0MONITORING_DSL_GENERAL
+    metrics:
+      - name: dsl_trellis
+        help: Trellis coding
+        path: 'ct.0.0MONITORING_DSL_GENERAL.#(Name=="Setting").Trellis'
+        parser: option
+      - name: dsl_stream_downstream_bps
+        help: Downstream rate of the bearer
+        path: 'ct.0.0MONITORING_DSL_GENERAL.#(Name=="Setting").Stream_Table'
+        value: Downstream
+        parser: kbps
+        labels:
+          bearer: Name
+      - name: dsl_stream_unlabeled_bps
+        path: 'ct.0.0MONITORING_DSL_GENERAL.#(Name=="Setting").Stream_Table'
+        value: Downstream
+        parser: kbps
+`
+
+func TestCustomCollector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(customConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCustomCollector(replayVigor(t, "testdata/vigor_v5/custom_dsl"), cfg.Modules, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The duplicate Bearer1 element and the unlabeled array are skipped.
+	want := `
+# HELP draytek_custom_dsl_stream_downstream_bps Downstream rate of the bearer
+# TYPE draytek_custom_dsl_stream_downstream_bps gauge
+draytek_custom_dsl_stream_downstream_bps{bearer="Bearer0"} 1e+08
+draytek_custom_dsl_stream_downstream_bps{bearer="Bearer1"} 2e+06
+# HELP draytek_custom_dsl_trellis Trellis coding
+# TYPE draytek_custom_dsl_trellis gauge
+draytek_custom_dsl_trellis 1
+`
+	if err := testutil.CollectAndCompare(updateCollector{c}, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	err = c.(*CustomCollector).Update(make(chan prometheus.Metric, 10))
+	if err == nil || !strings.Contains(err.Error(), "duplicate labels") || !strings.Contains(err.Error(), "no labels") {
+		t.Errorf("got error %v, want duplicate labels and no labels errors", err)
+	}
+}
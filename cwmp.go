@@ -0,0 +1,89 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/SuperQ/draytek_exporter/cwmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	cwmpLastInformDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cwmp", "last_inform_timestamp_seconds"),
+		"The time of the last CWMP Inform received from the device",
+		nil, nil,
+	)
+)
+
+// cwmpDeviceCollector exports the time of the last Inform of a device.
+type cwmpDeviceCollector struct {
+	d *cwmp.Device
+}
+
+func (c cwmpDeviceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cwmpLastInformDesc
+}
+
+func (c cwmpDeviceCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(
+		cwmpLastInformDesc, prometheus.GaugeValue, float64(c.d.LastInform().Unix()),
+	)
+}
+
+// cwmpProbeHandler serves the metrics of a single CWMP device, selected by the
+// target URL parameter.
+func cwmpProbeHandler(s *cwmp.Server, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "'target' parameter must be specified", http.StatusBadRequest)
+			return
+		}
+		d, ok := s.Device(target)
+		if !ok {
+			http.Error(w, "unknown target "+target, http.StatusNotFound)
+			return
+		}
+
+		collector, err := NewDrayTekCollector(d, nil, getCustomModules(), logger.With("target", target))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(collector, cwmpDeviceCollector{d: d})
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// cwmpTargetsHandler lists the CWMP devices in the Prometheus HTTP service
+// discovery format.
+func cwmpTargetsHandler(s *cwmp.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targets := []struct {
+			Targets []string          `json:"targets"`
+			Labels  map[string]string `json:"labels"`
+		}{
+			{Targets: s.Devices(), Labels: map[string]string{}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(targets)
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cwmp
+
+import (
+	"strconv"
+	"strings"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+)
+
+const (
+	dslInterfaceConfig = "WANDSLInterfaceConfig."
+)
+
+// dslParameterPaths are the partial paths requested from the CPE after an
+// Inform.
+var dslParameterPaths = []string{
+	"InternetGatewayDevice.WANDevice.1.WANDSLInterfaceConfig.",
+}
+
+// dslParameters returns the WANDSLInterfaceConfig parameters of the first
+// WANDevice, keyed by the path relative to WANDSLInterfaceConfig.
+func dslParameters(params map[string]string) map[string]string {
+	var device string
+	dsl := map[string]string{}
+	for name, value := range params {
+		prefix, param, ok := strings.Cut(name, dslInterfaceConfig)
+		if !ok {
+			continue
+		}
+		if device == "" || prefix < device {
+			device = prefix
+			clear(dsl)
+		}
+		if prefix != device {
+			continue
+		}
+		dsl[param] = value
+	}
+	return dsl
+}
+
+// statusFromParameters maps the TR-098 WANDSLInterfaceConfig parameters into
+// a vigorv5.Status. The near end is the CPE (ATU-R), the far end is the DSLAM
+// (ATU-C).
+func statusFromParameters(params map[string]string) vigorv5.Status {
+	dsl := dslParameters(params)
+
+	return vigorv5.Status{
+		Status:     dsl["Status"],
+		Mode:       dsl["ModulationType"],
+		Profile:    dsl["CurrentProfile"],
+		DSLVersion: dsl["StandardUsed"],
+
+		ActualRateDownstream:      parseKbps(dsl["DownstreamCurrRate"]),
+		ActualRateUpstream:        parseKbps(dsl["UpstreamCurrRate"]),
+		AttainableRateDownstream:  parseKbps(dsl["DownstreamMaxRate"]),
+		AttainableRateUpstream:    parseKbps(dsl["UpstreamMaxRate"]),
+		InterleaveDepthDownstream: parseInt(dsl["InterleaveDepth"]),
+		SNRMarginDownstream:       parseTenthdB(dsl["DownstreamNoiseMargin"]),
+		SNRMarginUpstream:         parseTenthdB(dsl["UpstreamNoiseMargin"]),
+
+		AttenuationNearEnd: parseTenthdB(dsl["DownstreamAttenuation"]),
+		AttenuationFarEnd:  parseTenthdB(dsl["UpstreamAttenuation"]),
+		CrcNearEnd:         parseInt(dsl["Stats.Total.CRCErrors"]),
+		CrcFarEnd:          parseInt(dsl["Stats.Total.ATUCCRCErrors"]),
+		EsNearEnd:          parseInt(dsl["Stats.Total.ErroredSecs"]),
+		SesNearEnd:         parseInt(dsl["Stats.Total.SeverelyErroredSecs"]),
+		HecErrorsNearEnd:   parseInt(dsl["Stats.Total.HECErrors"]),
+		HecErrorsFarEnd:    parseInt(dsl["Stats.Total.ATUCHECErrors"]),
+		LofFailureNearEnd:  parseInt(dsl["Stats.Total.LossOfFraming"]),
+		RfecNearEnd:        parseInt(dsl["Stats.Total.FECErrors"]),
+		RfecFarEnd:         parseInt(dsl["Stats.Total.ATUCFECErrors"]),
+	}
+}
+
+func parseInt(s string) int {
+	x, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return x
+}
+
+func parseKbps(s string) int {
+	return parseInt(s) * 1000
+}
+
+// parseTenthdB parses a value expressed in 0.1 dB units.
+func parseTenthdB(s string) float64 {
+	return float64(parseInt(s)) / 10
+}
@@ -0,0 +1,329 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cwmp
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+)
+
+var ErrNoStatus = errors.New("no status received from device")
+
+const (
+	sessionCookie  = "draytek_exporter_cwmp"
+	sessionTimeout = 5 * time.Minute
+	maxRequestSize = 1 << 20
+
+	// maxDevices and maxParameters bound the memory used by CPEs sending
+	// made up device IDs and parameters. Devices that have not sent an Inform
+	// for deviceTimeout are forgotten.
+	maxDevices    = 1000
+	maxParameters = 500
+	deviceTimeout = 24 * time.Hour
+)
+
+var ErrTooManyDevices = errors.New("too many devices")
+
+// Device is a CPE that has sent an Inform to the Server.
+type Device struct {
+	mtx        sync.Mutex
+	id         string
+	lastInform time.Time
+	params     map[string]string
+	status     vigorv5.Status
+	hasStatus  bool
+}
+
+// ID returns the device identifier, formatted as "<OUI>-<SerialNumber>".
+func (d *Device) ID() string {
+	return d.id
+}
+
+// LastInform returns the time of the last Inform received from the device.
+func (d *Device) LastInform() time.Time {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.lastInform
+}
+
+// FetchStatus returns the DSL status last reported by the device.
+func (d *Device) FetchStatus() (vigorv5.Status, error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if !d.hasStatus {
+		return vigorv5.Status{}, ErrNoStatus
+	}
+	return d.status, nil
+}
+
+func (d *Device) update(params []parameterValue, now time.Time, inform bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if inform {
+		d.lastInform = now
+	}
+	for _, p := range params {
+		// Only the DSL parameters are used.
+		if !strings.Contains(p.Name, dslInterfaceConfig) {
+			continue
+		}
+		if _, ok := d.params[p.Name]; !ok && len(d.params) >= maxParameters {
+			continue
+		}
+		d.params[p.Name] = p.Value
+	}
+	if len(dslParameters(d.params)) > 0 {
+		d.status = statusFromParameters(d.params)
+		d.hasStatus = true
+	}
+}
+
+type session struct {
+	device    *Device
+	namespace string
+	requested bool
+	started   time.Time
+}
+
+// Server is a minimal ACS that accepts Inform and GetParameterValues
+// exchanges from CPEs.
+type Server struct {
+	logger   *slog.Logger
+	username string
+	password string
+
+	mtx      sync.Mutex
+	devices  map[string]*Device
+	sessions map[string]*session
+	// deviceSessions maps a device ID to the ID of its session, so that
+	// repeated Informs from a device reuse the session.
+	deviceSessions map[string]string
+}
+
+// New returns a Server. If username is not empty, CPEs must authenticate with
+// HTTP basic auth.
+func New(logger *slog.Logger, username string, password string) *Server {
+	return &Server{
+		logger:         logger,
+		username:       username,
+		password:       password,
+		devices:        map[string]*Device{},
+		sessions:       map[string]*session{},
+		deviceSessions: map[string]string{},
+	}
+}
+
+// Device returns the device with the given ID.
+func (s *Server) Device(id string) (*Device, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	d, ok := s.devices[id]
+	return d, ok
+}
+
+// Devices returns the IDs of all devices that have sent an Inform within the
+// device timeout.
+func (s *Server) Devices() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.expireDevices(time.Now())
+	return slices.Sorted(maps.Keys(s.devices))
+}
+
+// ServeHTTP implements the CWMP ACS endpoint.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="draytek_exporter"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		s.logger.Debug("Unable to read CWMP request", "remote", r.RemoteAddr, "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	sess := s.session(r, now)
+
+	if len(body) == 0 {
+		// The CPE has nothing more to send, ask for the DSL parameters once per
+		// session and end the session afterwards.
+		s.mtx.Lock()
+		request := sess != nil && !sess.requested
+		if request {
+			sess.requested = true
+		}
+		s.mtx.Unlock()
+		if !request {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.write(w, encodeEnvelope(sess.namespace, newID(), encodeGetParameterValues(dslParameterPaths)))
+		return
+	}
+
+	env, err := decodeEnvelope(body)
+	if err != nil {
+		s.logger.Debug("Unable to decode CWMP envelope", "remote", r.RemoteAddr, "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case env.Body.Inform != nil:
+		inform := env.Body.Inform
+		id := inform.DeviceID.OUI + "-" + inform.DeviceID.SerialNumber
+		s.logger.Debug("Got CWMP Inform", "device", id, "remote", r.RemoteAddr, "events", inform.Events)
+
+		d, err := s.device(id, now)
+		if err != nil {
+			s.logger.Warn("Rejecting CWMP Inform", "device", id, "remote", r.RemoteAddr, "err", err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		d.update(inform.ParameterList, now, true)
+
+		sid := s.informSession(d, inform.XMLName.Space, now)
+
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: sid, HttpOnly: true})
+		s.write(w, encodeEnvelope(inform.XMLName.Space, env.Header.ID, informResponseBody))
+	case env.Body.GetParameterValuesResponse != nil:
+		if sess == nil {
+			s.logger.Debug("Got GetParameterValuesResponse without session", "remote", r.RemoteAddr)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.logger.Debug("Got CWMP GetParameterValuesResponse", "device", sess.device.ID(), "parameters", len(env.Body.GetParameterValuesResponse.ParameterList))
+		sess.device.update(env.Body.GetParameterValuesResponse.ParameterList, now, false)
+		w.WriteHeader(http.StatusNoContent)
+	case env.Body.Fault != nil:
+		s.logger.Debug("Got CWMP Fault", "remote", r.RemoteAddr, "code", env.Body.Fault.FaultCode, "fault", env.Body.Fault.FaultString)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.logger.Debug("Ignoring unsupported CWMP message", "remote", r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.username == "" {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(username), []byte(s.username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) == 1
+}
+
+// device returns the device with the given ID, adding it if there is room.
+func (s *Server) device(id string, now time.Time) (*Device, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	d, ok := s.devices[id]
+	if ok {
+		return d, nil
+	}
+	s.expireDevices(now)
+	if len(s.devices) >= maxDevices {
+		return nil, ErrTooManyDevices
+	}
+	d = &Device{id: id, lastInform: now, params: map[string]string{}}
+	s.devices[id] = d
+	return d, nil
+}
+
+// expireDevices removes devices that have not sent an Inform within the
+// device timeout. s.mtx must be held.
+func (s *Server) expireDevices(now time.Time) {
+	maps.DeleteFunc(s.devices, func(_ string, d *Device) bool {
+		return now.Sub(d.LastInform()) > deviceTimeout
+	})
+}
+
+// session returns the session for the request cookie, expiring old sessions.
+func (s *Server) session(r *http.Request, now time.Time) *session {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	maps.DeleteFunc(s.sessions, func(_ string, sess *session) bool {
+		return now.Sub(sess.started) > sessionTimeout
+	})
+	maps.DeleteFunc(s.deviceSessions, func(_ string, sid string) bool {
+		_, ok := s.sessions[sid]
+		return !ok
+	})
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	return s.sessions[cookie.Value]
+}
+
+// informSession starts a new session for an Inform from d and returns its ID.
+// The session of a previous Inform from the device is reused.
+func (s *Server) informSession(d *Device, namespace string, now time.Time) string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if sid, ok := s.deviceSessions[d.ID()]; ok {
+		if sess, ok := s.sessions[sid]; ok && sess.device == d {
+			sess.namespace = namespace
+			sess.requested = false
+			sess.started = now
+			return sid
+		}
+	}
+	sid := newID()
+	s.sessions[sid] = &session{
+		device:    d,
+		namespace: namespace,
+		started:   now,
+	}
+	s.deviceSessions[d.ID()] = sid
+	return sid
+}
+
+func (s *Server) write(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.Write(body)
+}
+
+func newID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
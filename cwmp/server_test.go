@@ -0,0 +1,209 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cwmp
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+)
+
+// post sends body to s with the cookies of the previous responses.
+func post(t *testing.T, s *Server, cookies []*http.Cookie, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/cwmp", bytes.NewReader(body))
+	req.SetBasicAuth("acs", "secret")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestInformSession(t *testing.T) {
+	s := New(slog.New(slog.DiscardHandler), "acs", "secret")
+
+	w := post(t, s, nil, readTestdata(t, "inform.xml"))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "InformResponse") {
+		t.Fatalf("inform: got %d %q", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), ">1001<") {
+		t.Errorf("InformResponse does not echo the request ID: %q", w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+
+	d, ok := s.Device("001DAA-2101234567890")
+	if !ok {
+		t.Fatalf("device not added, got %v", s.Devices())
+	}
+	status, err := d.FetchStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ActualRateDownstream != 50000000 {
+		t.Errorf("got downstream rate %d from Inform, want 50000000", status.ActualRateDownstream)
+	}
+
+	// The CPE has nothing more to send, the ACS asks for the DSL parameters.
+	w = post(t, s, cookies, nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<string>InternetGatewayDevice.WANDevice.1.WANDSLInterfaceConfig.</string>") {
+		t.Fatalf("empty post: got %d %q", w.Code, w.Body.String())
+	}
+
+	w = post(t, s, cookies, readTestdata(t, "get_parameter_values_response.xml"))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("GetParameterValuesResponse: got %d %q", w.Code, w.Body.String())
+	}
+
+	// The parameters were only requested once, the session ends.
+	w = post(t, s, cookies, nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("second empty post: got %d %q", w.Code, w.Body.String())
+	}
+
+	status, err = d.FetchStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := vigorv5.Status{
+		Status:                   "Up",
+		Mode:                     "VDSL2",
+		Profile:                  "17a",
+		ActualRateDownstream:     80000000,
+		ActualRateUpstream:       20000000,
+		AttainableRateDownstream: 95000000,
+		AttainableRateUpstream:   24000000,
+		SNRMarginDownstream:      6.2,
+		SNRMarginUpstream:        8.5,
+		AttenuationNearEnd:       12.5,
+		AttenuationFarEnd:        9.1,
+		CrcNearEnd:               42,
+		CrcFarEnd:                17,
+		EsNearEnd:                3,
+		RfecNearEnd:              1234,
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("got  %+v\nwant %+v", status, want)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	s := New(slog.New(slog.DiscardHandler), "acs", "other")
+	w := post(t, s, nil, readTestdata(t, "inform.xml"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if len(s.Devices()) != 0 {
+		t.Errorf("unauthorized Inform added devices %v", s.Devices())
+	}
+}
+
+func TestMaxDevices(t *testing.T) {
+	s := New(slog.New(slog.DiscardHandler), "acs", "secret")
+	inform := string(readTestdata(t, "inform.xml"))
+	for i := range maxDevices + 1 {
+		body := strings.Replace(inform, "2101234567890", fmt.Sprint(i), 1)
+		w := post(t, s, nil, []byte(body))
+		if i < maxDevices && w.Code != http.StatusOK {
+			t.Fatalf("device %d: got %d", i, w.Code)
+		}
+		if i == maxDevices && w.Code != http.StatusServiceUnavailable {
+			t.Errorf("device over the limit: got %d, want %d", w.Code, http.StatusServiceUnavailable)
+		}
+	}
+
+	// Expired devices make room for new ones.
+	for _, id := range s.Devices() {
+		d, _ := s.Device(id)
+		d.mtx.Lock()
+		d.lastInform = time.Now().Add(-2 * deviceTimeout)
+		d.mtx.Unlock()
+	}
+	w := post(t, s, nil, []byte(inform))
+	if w.Code != http.StatusOK {
+		t.Errorf("after expiry: got %d", w.Code)
+	}
+	if n := len(s.Devices()); n != 1 {
+		t.Errorf("got %d devices after expiry, want 1", n)
+	}
+}
+
+func TestInformReusesSession(t *testing.T) {
+	s := New(slog.New(slog.DiscardHandler), "acs", "secret")
+	inform := readTestdata(t, "inform.xml")
+
+	var sid string
+	for i := range 3 {
+		w := post(t, s, nil, inform)
+		if w.Code != http.StatusOK {
+			t.Fatalf("inform %d: got %d %q", i, w.Code, w.Body.String())
+		}
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("inform %d: got cookies %v", i, cookies)
+		}
+		if i > 0 && cookies[0].Value != sid {
+			t.Errorf("inform %d: got session %q, want %q", i, cookies[0].Value, sid)
+		}
+		sid = cookies[0].Value
+
+		// Each Inform starts a new exchange, the parameters are requested
+		// again.
+		w = post(t, s, cookies, nil)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "GetParameterValues") {
+			t.Fatalf("inform %d: empty post got %d %q", i, w.Code, w.Body.String())
+		}
+	}
+
+	s.mtx.Lock()
+	sessions, deviceSessions := len(s.sessions), len(s.deviceSessions)
+	s.mtx.Unlock()
+	if sessions != 1 || deviceSessions != 1 {
+		t.Errorf("got %d sessions and %d device sessions after repeated Informs, want 1", sessions, deviceSessions)
+	}
+
+	// Another device gets its own session.
+	w := post(t, s, nil, []byte(strings.Replace(string(inform), "2101234567890", "2109876543210", 1)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("other device: got %d", w.Code)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Value == sid {
+		t.Errorf("other device: got cookies %v, want a new session", cookies)
+	}
+	s.mtx.Lock()
+	sessions = len(s.sessions)
+	s.mtx.Unlock()
+	if sessions != 2 {
+		t.Errorf("got %d sessions for two devices, want 2", sessions)
+	}
+}
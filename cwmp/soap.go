@@ -0,0 +1,99 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cwmp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultCWMPNamespace = "urn:dslforum-org:cwmp-1-0"
+
+	envelopeTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:soapenc="http://schemas.xmlsoap.org/soap/encoding/" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:cwmp="%s">
+<soapenv:Header><cwmp:ID soapenv:mustUnderstand="1">%s</cwmp:ID></soapenv:Header>
+<soapenv:Body>%s</soapenv:Body>
+</soapenv:Envelope>`
+	informResponseBody      = `<cwmp:InformResponse><MaxEnvelopes>1</MaxEnvelopes></cwmp:InformResponse>`
+	getParameterValuesBody  = `<cwmp:GetParameterValues><ParameterNames soapenc:arrayType="xsd:string[%d]">%s</ParameterNames></cwmp:GetParameterValues>`
+	getParameterValuesNames = `<string>%s</string>`
+)
+
+type envelope struct {
+	Header struct {
+		ID string `xml:"ID"`
+	} `xml:"Header"`
+	Body struct {
+		Inform                     *inform                     `xml:"Inform"`
+		GetParameterValuesResponse *getParameterValuesResponse `xml:"GetParameterValuesResponse"`
+		Fault                      *fault                      `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+type inform struct {
+	XMLName  xml.Name
+	DeviceID struct {
+		Manufacturer string `xml:"Manufacturer"`
+		OUI          string `xml:"OUI"`
+		ProductClass string `xml:"ProductClass"`
+		SerialNumber string `xml:"SerialNumber"`
+	} `xml:"DeviceId"`
+	Events        []string         `xml:"Event>EventStruct>EventCode"`
+	ParameterList []parameterValue `xml:"ParameterList>ParameterValueStruct"`
+}
+
+type getParameterValuesResponse struct {
+	ParameterList []parameterValue `xml:"ParameterList>ParameterValueStruct"`
+}
+
+type fault struct {
+	FaultCode   string `xml:"faultcode"`
+	FaultString string `xml:"faultstring"`
+	Detail      string `xml:",innerxml"`
+}
+
+type parameterValue struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+func decodeEnvelope(body []byte) (envelope, error) {
+	var env envelope
+	err := xml.Unmarshal(body, &env)
+	return env, err
+}
+
+func encodeEnvelope(namespace string, id string, body string) []byte {
+	if namespace == "" {
+		namespace = defaultCWMPNamespace
+	}
+	return fmt.Appendf(nil, envelopeTemplate, escape(namespace), escape(id), body)
+}
+
+func encodeGetParameterValues(names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, getParameterValuesNames, escape(name))
+	}
+	return fmt.Sprintf(getParameterValuesBody, len(names), b.String())
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,61 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"net/http"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/alecthomas/kingpin/v2"
+)
+
+var (
+	recordDir = kingpin.Flag("debug.record-dir", "Directory to record the redacted exchanges with the Vigor v5 target to, empty to disable").Default("").String()
+	replayDir = kingpin.Flag("debug.replay-dir", "Directory of recorded exchanges to serve instead of talking to a Vigor v5 target, empty to disable").Default("").String()
+
+	unsafeDebug = kingpin.Flag("log.unsafe-debug", "Log cookies, tokens, password hashes and other secrets without redaction, for local troubleshooting only").Default("false").Bool()
+	redactKeys  = kingpin.Flag("log.redact-key", "Additional JSON key whose values are redacted in log messages and recorded exchanges, can be repeated").Strings()
+)
+
+// vigorOptions returns the Vigor v5 client options for the debug, log and
+// time zone flags.
+func vigorOptions() ([]vigorv5.Option, error) {
+	location, err := voipLocation()
+	if err != nil {
+		return nil, err
+	}
+	opts := []vigorv5.Option{
+		vigorv5.WithUnsafeDebug(*unsafeDebug),
+		vigorv5.WithRedactKeys(*redactKeys...),
+		vigorv5.WithLocation(location),
+	}
+
+	var rt http.RoundTripper
+	if *replayDir != "" {
+		rt, err = vigorv5.NewReplayTransport(*replayDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if *recordDir != "" {
+		rt, err = vigorv5.NewRecordTransport(*recordDir, rt, *redactKeys...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rt != nil {
+		opts = append(opts, vigorv5.WithTransport(rt))
+	}
+	return opts, nil
+}
@@ -0,0 +1,191 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package detect fingerprints which API a DrayTek router speaks.
+//
+// The probes are tried in order, the first match wins:
+//
+//   - `/cgi-bin/webproc.cgi` returning a prefix padded base64 body is the
+//     Vigor v5 JSON API.
+//   - `/api/` returning JSON is the DrayOS REST API.
+//   - A login page posting to `wlogin.cgi` is the legacy HTML UI, a login page
+//     referencing `webproc.cgi` is the Vigor v5 JSON API.
+package detect
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnknownAPI = errors.New("unable to detect device API")
+
+const (
+	APIVigorV5    = "vigor_v5"
+	APIDrayOSREST = "drayos_rest"
+	APILegacyHTML = "legacy_html"
+
+	maxBodySize = 64 << 10
+)
+
+// Result describes the detected API of a target.
+type Result struct {
+	API string
+	// Server is the HTTP Server header returned by the target, if any.
+	Server string
+}
+
+// Detector probes targets and caches the result per target.
+type Detector struct {
+	logger *slog.Logger
+	client *http.Client
+
+	mtx   sync.Mutex
+	cache map[string]Result
+}
+
+// New returns a Detector using the given timeout for each probe. TLS
+// certificates are only verified if insecureSkipVerify is false.
+func New(logger *slog.Logger, timeout time.Duration, insecureSkipVerify bool) *Detector {
+	return &Detector{
+		logger: logger,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
+			},
+			CheckRedirect: sameHost,
+		},
+		cache: map[string]Result{},
+	}
+}
+
+// sameHost only follows redirects to the probed host, as newer firmware
+// redirects to HTTPS. No credentials are sent while probing.
+func sameHost(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.URL.Hostname() != via[0].URL.Hostname() {
+		return http.ErrUseLastResponse
+	}
+	return nil
+}
+
+// Detect returns the API of the host, probing it only on the first call.
+func (d *Detector) Detect(host string) (Result, error) {
+	d.mtx.Lock()
+	r, ok := d.cache[host]
+	d.mtx.Unlock()
+	if ok {
+		return r, nil
+	}
+
+	r, err := d.probe(host)
+	if err != nil {
+		return r, err
+	}
+	d.logger.Info("Detected device API", "target", host, "api", r.API, "server", r.Server)
+
+	d.mtx.Lock()
+	d.cache[host] = r
+	d.mtx.Unlock()
+	return r, nil
+}
+
+// Forget removes the cached result for the host.
+func (d *Detector) Forget(host string) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	delete(d.cache, host)
+}
+
+func (d *Detector) probe(host string) (Result, error) {
+	var r Result
+
+	resp, body, err := d.get(fmt.Sprintf("http://%s/cgi-bin/webproc.cgi", host))
+	if err != nil {
+		return r, err
+	}
+	r.Server = resp.Header.Get("Server")
+	if resp.StatusCode == http.StatusOK && isPrefixPaddedBase64(body) {
+		r.API = APIVigorV5
+		return r, nil
+	}
+
+	resp, body, err = d.get(fmt.Sprintf("http://%s/api/", host))
+	if err == nil && isJSON(resp) && len(body) > 0 {
+		r.API = APIDrayOSREST
+		return r, nil
+	}
+
+	resp, body, err = d.get(fmt.Sprintf("http://%s/", host))
+	if err != nil {
+		return r, err
+	}
+	if r.Server == "" {
+		r.Server = resp.Header.Get("Server")
+	}
+	page := string(body)
+	switch {
+	case strings.Contains(page, "wlogin.cgi"):
+		r.API = APILegacyHTML
+	case strings.Contains(page, "webproc.cgi"):
+		r.API = APIVigorV5
+	default:
+		d.logger.Debug("No known markers found", "target", host, "server", r.Server)
+		return r, ErrUnknownAPI
+	}
+	return r, nil
+}
+
+func (d *Detector) get(url string) (*http.Response, []byte, error) {
+	resp, err := d.client.Get(url)
+	if err != nil {
+		d.logger.Debug("Probe failed", "url", url, "err", err)
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, nil, err
+	}
+	d.logger.Debug("Probe returned", "url", url, "status", resp.Status, "size", len(body))
+	return resp, body, nil
+}
+
+func isJSON(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// isPrefixPaddedBase64 reports whether the body looks like a Vigor v5 API
+// response, see the vigorv5 package for details on the encoding.
+func isPrefixPaddedBase64(body []byte) bool {
+	if len(body) < 2 || body[0] < '0' || body[0] > '2' {
+		return false
+	}
+	encoded := string(body[1:]) + strings.Repeat("=", int(body[0]-'0'))
+	_, err := base64.StdEncoding.DecodeString(encoded)
+	return err == nil
+}
@@ -0,0 +1,113 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package detect
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// redirectTo returns a server redirecting all requests to base.
+func redirectTo(t *testing.T, base string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, base+r.URL.Path, http.StatusFound)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func vigorV5Server(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cgi-bin/webproc.cgi" {
+			// "abc" in the prefix padded base64 encoding.
+			_, _ = w.Write([]byte("0YWJj"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func host(srv *httptest.Server) string {
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestDetectHTTPSRedirect(t *testing.T) {
+	redirect := redirectTo(t, vigorV5Server(t).URL)
+
+	d := New(slog.New(slog.DiscardHandler), time.Second, true)
+	r, err := d.Detect(host(redirect))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.API != APIVigorV5 {
+		t.Errorf("got API %q, want %q", r.API, APIVigorV5)
+	}
+}
+
+func TestDetectVerifiesCertificate(t *testing.T) {
+	redirect := redirectTo(t, vigorV5Server(t).URL)
+
+	d := New(slog.New(slog.DiscardHandler), time.Second, false)
+	if _, err := d.Detect(host(redirect)); err == nil {
+		t.Error("expected an error for the self-signed certificate")
+	}
+}
+
+func TestDetectOtherHostRedirect(t *testing.T) {
+	redirect := redirectTo(t, "http://router.invalid")
+
+	d := New(slog.New(slog.DiscardHandler), time.Second, true)
+	if _, err := d.Detect(host(redirect)); !errors.Is(err, ErrUnknownAPI) {
+		t.Errorf("got error %v, want %v", err, ErrUnknownAPI)
+	}
+}
+
+func TestDetectLegacyHTML(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			_, _ = w.Write([]byte(`<form method="post" action="/cgi-bin/wlogin.cgi">`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	d := New(slog.New(slog.DiscardHandler), time.Second, false)
+	r, err := d.Detect(host(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.API != APILegacyHTML {
+		t.Errorf("got API %q, want %q", r.API, APILegacyHTML)
+	}
+
+	// The result is cached until it is forgotten.
+	srv.Close()
+	if r, err := d.Detect(host(srv)); err != nil || r.API != APILegacyHTML {
+		t.Errorf("got cached result %+v, %v", r, err)
+	}
+	d.Forget(host(srv))
+	if _, err := d.Detect(host(srv)); err == nil {
+		t.Error("expected an error after forgetting the result")
+	}
+}
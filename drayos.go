@@ -0,0 +1,141 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"log/slog"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	"github.com/SuperQ/draytek_exporter/drayos"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("system", defaultEnabled, NewDrayOSCollector)
+}
+
+// DrayOSCollector collects interface and system stats from the DrayOS REST
+// API.
+type DrayOSCollector struct {
+	c      *drayos.Client
+	logger *slog.Logger
+}
+
+// NewDrayOSCollector returns an initialized DrayOSCollector.
+func NewDrayOSCollector(device StatusFetcher, modules []config.Module, logger *slog.Logger) (Collector, error) {
+	c, ok := device.(*drayos.Client)
+	if !ok {
+		return nil, ErrUnsupportedDevice
+	}
+	return &DrayOSCollector{c: c, logger: logger}, nil
+}
+
+var (
+	systemInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "system", "info"),
+		"Info about the draytek router model and firmware",
+		[]string{"model", "firmware"}, nil,
+	)
+	systemUptimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "system", "uptime_seconds"),
+		"The number of seconds since the router booted",
+		nil, nil,
+	)
+	systemCPUUsageDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "system", "cpu_usage_ratio"),
+		"The CPU usage of the router",
+		nil, nil,
+	)
+	systemMemoryUsageDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "system", "memory_usage_ratio"),
+		"The memory usage of the router",
+		nil, nil,
+	)
+
+	interfaceUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "interface", "up"),
+		"Whether the interface is up",
+		[]string{"interface", "type"}, nil,
+	)
+	interfaceRxBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "interface", "receive_bytes_total"),
+		"The number of bytes received on the interface",
+		[]string{"interface"}, nil,
+	)
+	interfaceTxBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "interface", "transmit_bytes_total"),
+		"The number of bytes transmitted on the interface",
+		[]string{"interface"}, nil,
+	)
+	interfaceRxPacketsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "interface", "receive_packets_total"),
+		"The number of packets received on the interface",
+		[]string{"interface"}, nil,
+	)
+	interfaceTxPacketsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "interface", "transmit_packets_total"),
+		"The number of packets transmitted on the interface",
+		[]string{"interface"}, nil,
+	)
+)
+
+// Update fetches the system and interface stats from the router and delivers
+// them as Prometheus metrics.
+func (c *DrayOSCollector) Update(ch chan<- prometheus.Metric) error {
+	system, err := c.c.FetchSystem()
+	if err != nil {
+		return err
+	}
+	interfaces, err := c.c.FetchInterfaces()
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		systemInfoDesc, prometheus.GaugeValue, 1.0,
+		system.Model, system.Firmware,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		systemUptimeDesc, prometheus.GaugeValue, float64(system.UptimeSeconds),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		systemCPUUsageDesc, prometheus.GaugeValue, system.CPUUsage/100,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		systemMemoryUsageDesc, prometheus.GaugeValue, system.MemoryUsage/100,
+	)
+
+	for _, iface := range interfaces {
+		ch <- prometheus.MustNewConstMetric(
+			interfaceUpDesc, prometheus.GaugeValue, optionToFloat64(iface.Up),
+			iface.Name, iface.Type,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			interfaceRxBytesDesc, prometheus.CounterValue, float64(iface.RxBytes),
+			iface.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			interfaceTxBytesDesc, prometheus.CounterValue, float64(iface.TxBytes),
+			iface.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			interfaceRxPacketsDesc, prometheus.CounterValue, float64(iface.RxPacket),
+			iface.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			interfaceTxPacketsDesc, prometheus.CounterValue, float64(iface.TxPacket),
+			iface.Name,
+		)
+	}
+	return nil
+}
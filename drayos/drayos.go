@@ -0,0 +1,295 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package drayos provides a client for the REST/JSON API of newer DrayOS
+// firmware, such as the Vigor 2865 and 3910.
+//
+// The API authenticates with a token returned by `POST /api/login`, which is
+// sent as a bearer token on subsequent requests. An expired token is answered
+// with 401 Unauthorized, after which the client logs in again.
+package drayos
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var ErrLoginFailed = errors.New("login failed")
+var ErrCredentialsFailed = errors.New("unable to get credentials")
+var ErrLogoutFailed = errors.New("logout failed")
+var ErrRequestFailed = errors.New("request failed")
+
+const (
+	loginPath      = "/api/login"
+	logoutPath     = "/api/logout"
+	dslPath        = "/api/status/dsl"
+	interfacesPath = "/api/status/interfaces"
+	systemPath     = "/api/status/system"
+
+	requestTimeout = 10 * time.Second
+)
+
+// Client talks to the DrayOS REST API of a single router.
+type Client struct {
+	logger  *slog.Logger
+	client  *http.Client
+	baseURL *url.URL
+
+	username    string
+	password    string
+	credentials CredentialsFunc
+
+	mtx       sync.Mutex
+	token     string
+	loginTime time.Time
+	logins    uint64
+	relogins  uint64
+	// rejected is set when the last login failed, to refresh the
+	// credentials on the next login.
+	rejected bool
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// CredentialsFunc returns the username and password to log in with. refresh
+// is true after the router rejected the previous login, so that cached
+// credentials can be fetched again.
+type CredentialsFunc func(refresh bool) (username string, password string, err error)
+
+// WithCredentials sets a function that is called on every login to get the
+// credentials, instead of using the username and password passed to New.
+func WithCredentials(f CredentialsFunc) Option {
+	return func(c *Client) {
+		c.credentials = f
+	}
+}
+
+// New returns a Client for the router at baseURL, for example
+// "https://192.168.1.1".
+func New(logger *slog.Logger, baseURL string, username string, password string, insecureSkipVerify bool, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		logger:  logger,
+		baseURL: u,
+		client: &http.Client{
+			Timeout: requestTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
+			},
+		},
+		username: username,
+		password: password,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token string `json:"token"`
+}
+
+// Login requests a new API token.
+func (c *Client) Login() error {
+	username, password := c.username, c.password
+	if c.credentials != nil {
+		c.mtx.Lock()
+		refresh := c.rejected
+		c.mtx.Unlock()
+
+		var err error
+		username, password, err = c.credentials(refresh)
+		if err != nil {
+			c.logger.Debug("Unable to get credentials", "err", err)
+			return fmt.Errorf("%w: %w", ErrCredentialsFailed, err)
+		}
+	}
+
+	err := c.login(username, password)
+	c.mtx.Lock()
+	c.rejected = errors.Is(err, ErrLoginFailed)
+	c.mtx.Unlock()
+	return err
+}
+
+func (c *Client) login(username string, password string) error {
+	body, err := json.Marshal(loginRequest{Username: username, Password: password})
+	if err != nil {
+		return err
+	}
+
+	c.logger.Debug("Attempting login", "username", username)
+	resp, err := c.client.Post(c.baseURL.JoinPath(loginPath).String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.logger.Debug("Server returned non-ok http status", "status", resp.Status)
+		return ErrLoginFailed
+	}
+
+	var login loginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		c.logger.Debug("Decoding response failed", "err", err)
+		return ErrLoginFailed
+	}
+	if login.Token == "" {
+		return ErrLoginFailed
+	}
+
+	c.mtx.Lock()
+	c.token = login.Token
+	c.loginTime = time.Now()
+	c.logins++
+	c.mtx.Unlock()
+	c.logger.Debug("Login OK")
+
+	return nil
+}
+
+// Logout invalidates the current API token.
+func (c *Client) Logout() error {
+	token := c.currentToken()
+	if token == "" {
+		return nil
+	}
+	c.setToken("")
+
+	c.logger.Debug("Attempting logout")
+	req, err := http.NewRequest(http.MethodPost, c.baseURL.JoinPath(logoutPath).String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		c.logger.Debug("Logout OK")
+		return nil
+	default:
+		c.logger.Debug("Server returned non-ok http status", "status", resp.Status)
+		return ErrLogoutFailed
+	}
+}
+
+// SessionStart returns the time the current token was issued, or the zero
+// time if there is no token.
+func (c *Client) SessionStart() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.token == "" {
+		return time.Time{}
+	}
+	return c.loginTime
+}
+
+// Logins returns the number of successful logins.
+func (c *Client) Logins() uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.logins
+}
+
+// Relogins returns the number of requests that were rejected with an expired
+// token and needed a new login.
+func (c *Client) Relogins() uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.relogins
+}
+
+// get fetches path into v, logging in first if there is no token or the token
+// has expired.
+func (c *Client) get(path string, v any) error {
+	for range 2 {
+		token := c.currentToken()
+		if token == "" {
+			if err := c.Login(); err != nil {
+				return err
+			}
+			token = c.currentToken()
+		}
+
+		req, err := http.NewRequest(http.MethodGet, c.baseURL.JoinPath(path).String(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			c.logger.Debug("Got response", "path", path, "size", len(body))
+			return json.Unmarshal(body, v)
+		case http.StatusUnauthorized:
+			c.logger.Debug("Token expired, attempting login", "path", path)
+			c.mtx.Lock()
+			c.token = ""
+			c.relogins++
+			c.mtx.Unlock()
+		default:
+			return fmt.Errorf("%w: %s returned %s", ErrRequestFailed, path, resp.Status)
+		}
+	}
+	return ErrRequestFailed
+}
+
+func (c *Client) currentToken() string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.token
+}
+
+func (c *Client) setToken(token string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.token = token
+}
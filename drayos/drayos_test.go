@@ -0,0 +1,262 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package drayos
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+)
+
+// router is a stand-in for the DrayOS REST API serving the files in testdata.
+type router struct {
+	t *testing.T
+
+	mtx    sync.Mutex
+	issued int
+	tokens map[string]bool
+	logins int
+}
+
+func newRouter(t *testing.T) (*router, *httptest.Server) {
+	t.Helper()
+	r := &router{t: t, tokens: map[string]bool{}}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+// expire invalidates all issued tokens.
+func (r *router) expire() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.tokens = map[string]bool{}
+}
+
+func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if req.URL.Path == loginPath {
+		var login loginRequest
+		if err := json.NewDecoder(req.Body).Decode(&login); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if login.Username != "monitor" || login.Password != "secret" {
+			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		r.issued++
+		r.logins++
+		token := "token-" + strconv.Itoa(r.issued)
+		r.tokens[token] = true
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(loginResponse{Token: token})
+		return
+	}
+
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || !r.tokens[token] {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if req.URL.Path == logoutPath {
+		delete(r.tokens, token)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	name, ok := strings.CutPrefix(req.URL.Path, "/api/status/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	body, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+func testClient(t *testing.T, srv *httptest.Server, password string, opts ...Option) *Client {
+	t.Helper()
+	c, err := New(slog.New(slog.DiscardHandler), srv.URL, "monitor", password, false, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestFetchStatus(t *testing.T) {
+	_, srv := newRouter(t)
+	c := testClient(t, srv, "secret")
+
+	got, err := c.FetchStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := vigorv5.Status{
+		Status:     "SHOWTIME",
+		Mode:       "VDSL2",
+		Profile:    "17a",
+		Annex:      "B",
+		DSLVersion: "08-0E-09-07-00-07",
+
+		ActualRateDownstream:      109999000,
+		ActualRateUpstream:        39999000,
+		AttainableRateDownstream:  134332000,
+		AttainableRateUpstream:    43546000,
+		InterleaveDepthDownstream: 1,
+		InterleaveDepthUpstream:   1,
+		ActualPSDDownstream:       -15.3,
+		ActualPSDUpstream:         -17.8,
+		SNRMarginDownstream:       9.2,
+		SNRMarginUpstream:         7.1,
+
+		BitswapNearEnd:     true,
+		BitswapFarEnd:      true,
+		ReTxNearEnd:        true,
+		AttenuationNearEnd: 10.5,
+		CrcNearEnd:         12,
+		CrcFarEnd:          3,
+		EsNearEnd:          7,
+		EsFarEnd:           1,
+		SesNearEnd:         2,
+		UasNearEnd:         95,
+		UasFarEnd:          95,
+		LosFailureNearEnd:  1,
+		LprFailureFarEnd:   1,
+		RfecNearEnd:        4521,
+		RfecFarEnd:         17,
+	}
+	if got != want {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if c.Logins() != 1 {
+		t.Errorf("got %d logins, want 1", c.Logins())
+	}
+}
+
+func TestFetchInterfacesAndSystem(t *testing.T) {
+	_, srv := newRouter(t)
+	c := testClient(t, srv, "secret")
+
+	interfaces, err := c.FetchInterfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interfaces) != 2 {
+		t.Fatalf("got %d interfaces, want 2", len(interfaces))
+	}
+	wantWAN := Interface{Name: "WAN1", Type: "vdsl", Up: true, RxBytes: 123456789, TxBytes: 23456789, RxPacket: 98765, TxPacket: 54321}
+	if interfaces[0] != wantWAN {
+		t.Errorf("got %+v, want %+v", interfaces[0], wantWAN)
+	}
+
+	system, err := c.FetchSystem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSystem := System{Model: "Vigor2865", Firmware: "4.4.5", UptimeSeconds: 86400, CPUUsage: 3.5, MemoryUsage: 41.2}
+	if system != wantSystem {
+		t.Errorf("got %+v, want %+v", system, wantSystem)
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	r, srv := newRouter(t)
+	c := testClient(t, srv, "secret")
+
+	if err := c.Login(); err != nil {
+		t.Fatal(err)
+	}
+	first := c.SessionStart()
+	r.expire()
+
+	if _, err := c.FetchStatus(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Logins() != 2 {
+		t.Errorf("got %d logins, want 2", c.Logins())
+	}
+	if c.Relogins() != 1 {
+		t.Errorf("got %d relogins, want 1", c.Relogins())
+	}
+	if c.currentToken() != "token-2" {
+		t.Errorf("got token %q, want token-2", c.currentToken())
+	}
+	if c.SessionStart().Before(first) {
+		t.Errorf("session start %v is before the first login %v", c.SessionStart(), first)
+	}
+
+	if err := c.Logout(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.SessionStart().IsZero() {
+		t.Errorf("got session start %v after logout, want zero", c.SessionStart())
+	}
+}
+
+func TestLoginRejected(t *testing.T) {
+	r, srv := newRouter(t)
+	var refreshes []bool
+	c := testClient(t, srv, "", WithCredentials(func(refresh bool) (string, string, error) {
+		refreshes = append(refreshes, refresh)
+		if len(refreshes) == 1 {
+			return "monitor", "wrong", nil
+		}
+		return "monitor", "secret", nil
+	}))
+
+	if _, err := c.FetchStatus(); !errors.Is(err, ErrLoginFailed) {
+		t.Fatalf("got error %v, want %v", err, ErrLoginFailed)
+	}
+	if _, err := c.FetchStatus(); err != nil {
+		t.Fatal(err)
+	}
+	if len(refreshes) != 2 || refreshes[0] || !refreshes[1] {
+		t.Errorf("got credential refreshes %v, want [false true]", refreshes)
+	}
+	if r.logins != 1 {
+		t.Errorf("router accepted %d logins, want 1", r.logins)
+	}
+}
+
+func TestCredentialsFailed(t *testing.T) {
+	r, srv := newRouter(t)
+	c := testClient(t, srv, "", WithCredentials(func(refresh bool) (string, string, error) {
+		return "", "", errors.New("credential helper timed out")
+	}))
+
+	_, err := c.FetchStatus()
+	if !errors.Is(err, ErrCredentialsFailed) || errors.Is(err, ErrLoginFailed) {
+		t.Errorf("got error %v, want only %v", err, ErrCredentialsFailed)
+	}
+	if r.logins != 0 {
+		t.Errorf("router accepted %d logins, want 0", r.logins)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package drayos
+
+import (
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+)
+
+type dslStream struct {
+	ActualRateKbps     int     `json:"actual_rate_kbps"`
+	AttainableRateKbps int     `json:"attainable_rate_kbps"`
+	InterleaveDepth    int     `json:"interleave_depth"`
+	ActualPSD          float64 `json:"actual_psd_db"`
+	SNRMargin          float64 `json:"snr_margin_db"`
+}
+
+type dslEnd struct {
+	Bitswap     bool    `json:"bitswap"`
+	ReTx        bool    `json:"retx"`
+	Attenuation float64 `json:"attenuation_db"`
+	CRC         int     `json:"crc"`
+	ES          int     `json:"es"`
+	SES         int     `json:"ses"`
+	UAS         int     `json:"uas"`
+	HEC         int     `json:"hec"`
+	LOS         int     `json:"los"`
+	LOF         int     `json:"lof"`
+	LPR         int     `json:"lpr"`
+	LCD         int     `json:"lcd"`
+	FEC         int     `json:"fec"`
+}
+
+type dslStatus struct {
+	Status  string `json:"status"`
+	Mode    string `json:"mode"`
+	Profile string `json:"profile"`
+	Annex   string `json:"annex"`
+	Version string `json:"version"`
+
+	Downstream dslStream `json:"downstream"`
+	Upstream   dslStream `json:"upstream"`
+	NearEnd    dslEnd    `json:"near_end"`
+	FarEnd     dslEnd    `json:"far_end"`
+}
+
+// Interface is the status of a WAN or LAN interface.
+type Interface struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Up       bool   `json:"up"`
+	RxBytes  int    `json:"rx_bytes"`
+	TxBytes  int    `json:"tx_bytes"`
+	RxPacket int    `json:"rx_packets"`
+	TxPacket int    `json:"tx_packets"`
+}
+
+// System is the general status of the router.
+type System struct {
+	Model         string  `json:"model"`
+	Firmware      string  `json:"firmware"`
+	UptimeSeconds int     `json:"uptime_seconds"`
+	CPUUsage      float64 `json:"cpu_usage_percent"`
+	MemoryUsage   float64 `json:"memory_usage_percent"`
+}
+
+// FetchStatus returns the DSL status in the same form as the Vigor v5 API.
+func (c *Client) FetchStatus() (vigorv5.Status, error) {
+	var dsl dslStatus
+	if err := c.get(dslPath, &dsl); err != nil {
+		c.logger.Debug("Got error from get", "err", err)
+		return vigorv5.Status{}, err
+	}
+
+	return vigorv5.Status{
+		Status:     dsl.Status,
+		Mode:       dsl.Mode,
+		Profile:    dsl.Profile,
+		Annex:      dsl.Annex,
+		DSLVersion: dsl.Version,
+
+		ActualRateDownstream:      dsl.Downstream.ActualRateKbps * 1000,
+		ActualRateUpstream:        dsl.Upstream.ActualRateKbps * 1000,
+		AttainableRateDownstream:  dsl.Downstream.AttainableRateKbps * 1000,
+		AttainableRateUpstream:    dsl.Upstream.AttainableRateKbps * 1000,
+		InterleaveDepthDownstream: dsl.Downstream.InterleaveDepth,
+		InterleaveDepthUpstream:   dsl.Upstream.InterleaveDepth,
+		ActualPSDDownstream:       dsl.Downstream.ActualPSD,
+		ActualPSDUpstream:         dsl.Upstream.ActualPSD,
+		SNRMarginDownstream:       dsl.Downstream.SNRMargin,
+		SNRMarginUpstream:         dsl.Upstream.SNRMargin,
+
+		BitswapNearEnd:     dsl.NearEnd.Bitswap,
+		BitswapFarEnd:      dsl.FarEnd.Bitswap,
+		ReTxNearEnd:        dsl.NearEnd.ReTx,
+		ReTxFarEnd:         dsl.FarEnd.ReTx,
+		AttenuationNearEnd: dsl.NearEnd.Attenuation,
+		AttenuationFarEnd:  dsl.FarEnd.Attenuation,
+		CrcNearEnd:         dsl.NearEnd.CRC,
+		CrcFarEnd:          dsl.FarEnd.CRC,
+		EsNearEnd:          dsl.NearEnd.ES,
+		EsFarEnd:           dsl.FarEnd.ES,
+		SesNearEnd:         dsl.NearEnd.SES,
+		SesFarEnd:          dsl.FarEnd.SES,
+		UasNearEnd:         dsl.NearEnd.UAS,
+		UasFarEnd:          dsl.FarEnd.UAS,
+		HecErrorsNearEnd:   dsl.NearEnd.HEC,
+		HecErrorsFarEnd:    dsl.FarEnd.HEC,
+		LosFailureNearEnd:  dsl.NearEnd.LOS,
+		LosFailureFarEnd:   dsl.FarEnd.LOS,
+		LofFailureNearEnd:  dsl.NearEnd.LOF,
+		LofFailureFarEnd:   dsl.FarEnd.LOF,
+		LprFailureNearEnd:  dsl.NearEnd.LPR,
+		LprFailureFarEnd:   dsl.FarEnd.LPR,
+		LcdFailureNearEnd:  dsl.NearEnd.LCD,
+		LcdFailureFarEnd:   dsl.FarEnd.LCD,
+		RfecNearEnd:        dsl.NearEnd.FEC,
+		RfecFarEnd:         dsl.FarEnd.FEC,
+	}, nil
+}
+
+// FetchInterfaces returns the status of all interfaces.
+func (c *Client) FetchInterfaces() ([]Interface, error) {
+	var interfaces struct {
+		Interfaces []Interface `json:"interfaces"`
+	}
+	if err := c.get(interfacesPath, &interfaces); err != nil {
+		c.logger.Debug("Got error from get", "err", err)
+		return nil, err
+	}
+	return interfaces.Interfaces, nil
+}
+
+// FetchSystem returns the general system status.
+func (c *Client) FetchSystem() (System, error) {
+	var system System
+	if err := c.get(systemPath, &system); err != nil {
+		c.logger.Debug("Got error from get", "err", err)
+		return System{}, err
+	}
+	return system, nil
+}
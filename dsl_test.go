@@ -0,0 +1,110 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDSLCollectorV2(t *testing.T) {
+	c := &DSLCollector{
+		v:      replayVigor(t, "testdata/vigor_v5/dsl"),
+		schema: schemaV2,
+	}
+
+	want := `
+# HELP draytek_dsl_actual_bps The actual bits per second rate
+# TYPE draytek_dsl_actual_bps gauge
+draytek_dsl_actual_bps{direction="down"} 7.9999e+07
+draytek_dsl_actual_bps{direction="up"} 1.9999e+07
+# HELP draytek_dsl_actual_psd_db The actual power spectrum density in dB
+# TYPE draytek_dsl_actual_psd_db gauge
+draytek_dsl_actual_psd_db{direction="down"} -14.2
+draytek_dsl_actual_psd_db{direction="up"} -38.9
+# HELP draytek_dsl_attainable_bps The attainable bits per second rate
+# TYPE draytek_dsl_attainable_bps gauge
+draytek_dsl_attainable_bps{direction="down"} 1.05432e+08
+draytek_dsl_attainable_bps{direction="up"} 3.1234e+07
+# HELP draytek_dsl_attenuation_db The attenuation in dB
+# TYPE draytek_dsl_attenuation_db gauge
+draytek_dsl_attenuation_db{end="far"} 0
+draytek_dsl_attenuation_db{end="near"} 14.5
+# HELP draytek_dsl_bitswap_active Whether bitswap is active
+# TYPE draytek_dsl_bitswap_active gauge
+draytek_dsl_bitswap_active{end="far"} 0
+draytek_dsl_bitswap_active{end="near"} 1
+# HELP draytek_dsl_crc_errors_total The number of CRC errors
+# TYPE draytek_dsl_crc_errors_total counter
+draytek_dsl_crc_errors_total{end="far"} 3
+draytek_dsl_crc_errors_total{end="near"} 12
+# HELP draytek_dsl_errored_seconds_total The number of seconds the line was errored
+# TYPE draytek_dsl_errored_seconds_total counter
+draytek_dsl_errored_seconds_total{end="far"} 2
+draytek_dsl_errored_seconds_total{end="near"} 5
+# HELP draytek_dsl_hec_errors_total The number of header errors
+# TYPE draytek_dsl_hec_errors_total counter
+draytek_dsl_hec_errors_total{end="far"} 0
+draytek_dsl_hec_errors_total{end="near"} 4
+# HELP draytek_dsl_interleave_depth The amount of interleaving configured
+# TYPE draytek_dsl_interleave_depth gauge
+draytek_dsl_interleave_depth{direction="down"} 0
+draytek_dsl_interleave_depth{direction="up"} 0
+# HELP draytek_dsl_lcd_failures_total The number of Loss of Cell Delineation failures
+# TYPE draytek_dsl_lcd_failures_total counter
+draytek_dsl_lcd_failures_total{end="far"} 0
+draytek_dsl_lcd_failures_total{end="near"} 0
+# HELP draytek_dsl_lof_failures_total The number of Loss of Frame failures
+# TYPE draytek_dsl_lof_failures_total counter
+draytek_dsl_lof_failures_total{end="far"} 0
+draytek_dsl_lof_failures_total{end="near"} 0
+# HELP draytek_dsl_los_failures_total The number of Loss of Signal failures
+# TYPE draytek_dsl_los_failures_total counter
+draytek_dsl_los_failures_total{end="far"} 0
+draytek_dsl_los_failures_total{end="near"} 1
+# HELP draytek_dsl_lpr_failures_total The number of Loss of Power failures
+# TYPE draytek_dsl_lpr_failures_total counter
+draytek_dsl_lpr_failures_total{end="far"} 1
+draytek_dsl_lpr_failures_total{end="near"} 0
+# HELP draytek_dsl_reed_solomon_forward_error_corrections_total The number of Reed–Solomon Forward Error Corrections
+# TYPE draytek_dsl_reed_solomon_forward_error_corrections_total counter
+draytek_dsl_reed_solomon_forward_error_corrections_total{end="far"} 789
+draytek_dsl_reed_solomon_forward_error_corrections_total{end="near"} 123456
+# HELP draytek_dsl_retx_active Whether retransmission is active
+# TYPE draytek_dsl_retx_active gauge
+draytek_dsl_retx_active{end="far"} 1
+draytek_dsl_retx_active{end="near"} 1
+# HELP draytek_dsl_severely_errored_seconds_total The number of seconds the line was severely errored
+# TYPE draytek_dsl_severely_errored_seconds_total counter
+draytek_dsl_severely_errored_seconds_total{end="far"} 0
+draytek_dsl_severely_errored_seconds_total{end="near"} 1
+# HELP draytek_dsl_snr_margin_db The SNR margin in dB
+# TYPE draytek_dsl_snr_margin_db gauge
+draytek_dsl_snr_margin_db{direction="down"} 11.5
+draytek_dsl_snr_margin_db{direction="up"} 9.8
+# HELP draytek_dsl_unavailable_seconds_total The number of seconds the line was unavailable
+# TYPE draytek_dsl_unavailable_seconds_total counter
+draytek_dsl_unavailable_seconds_total{end="far"} 30
+draytek_dsl_unavailable_seconds_total{end="near"} 30
+# HELP draytek_info Info about the draytek router
+# TYPE draytek_info gauge
+draytek_info{dsl_version="08-0B-02-06-00-07",mode="VDSL2",profile="17a"} 1
+`
+	// Comparing all series also checks that no v1 series are reported.
+	if err := testutil.CollectAndCompare(updateCollector{c}, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	schemaV1 = "v1"
+	schemaV2 = "v2"
+)
+
+// The v2 schema uses shared metric names with a "direction" label for the
+// stream table and an "end" label for the end table.
+var (
+	dslActualRateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "actual_bps"),
+		"The actual bits per second rate",
+		[]string{"direction"}, nil,
+	)
+	dslAttainableRateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "attainable_bps"),
+		"The attainable bits per second rate",
+		[]string{"direction"}, nil,
+	)
+	dslInterleaveDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "interleave_depth"),
+		"The amount of interleaving configured",
+		[]string{"direction"}, nil,
+	)
+	dslActualPsdDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "actual_psd_db"),
+		"The actual power spectrum density in dB",
+		[]string{"direction"}, nil,
+	)
+	dslSnrMarginDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "snr_margin_db"),
+		"The SNR margin in dB",
+		[]string{"direction"}, nil,
+	)
+
+	dslBitswapActiveDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "bitswap_active"),
+		"Whether bitswap is active",
+		[]string{"end"}, nil,
+	)
+	dslReTxActiveDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "retx_active"),
+		"Whether retransmission is active",
+		[]string{"end"}, nil,
+	)
+	dslAttenuationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "attenuation_db"),
+		"The attenuation in dB",
+		[]string{"end"}, nil,
+	)
+	dslCrcCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "crc_errors_total"),
+		"The number of CRC errors",
+		[]string{"end"}, nil,
+	)
+	dslErroredSecondsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "errored_seconds_total"),
+		"The number of seconds the line was errored",
+		[]string{"end"}, nil,
+	)
+	dslSeverelyErroredSecondsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "severely_errored_seconds_total"),
+		"The number of seconds the line was severely errored",
+		[]string{"end"}, nil,
+	)
+	dslUnavailableSecondsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "unavailable_seconds_total"),
+		"The number of seconds the line was unavailable",
+		[]string{"end"}, nil,
+	)
+	dslHecErrorCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "hec_errors_total"),
+		"The number of header errors",
+		[]string{"end"}, nil,
+	)
+	dslLosFailureCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "los_failures_total"),
+		"The number of Loss of Signal failures",
+		[]string{"end"}, nil,
+	)
+	dslLofFailureCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "lof_failures_total"),
+		"The number of Loss of Frame failures",
+		[]string{"end"}, nil,
+	)
+	dslLprFailureCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "lpr_failures_total"),
+		"The number of Loss of Power failures",
+		[]string{"end"}, nil,
+	)
+	dslLcdFailureCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "lcd_failures_total"),
+		"The number of Loss of Cell Delineation failures",
+		[]string{"end"}, nil,
+	)
+	dslRfecCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dsl", "reed_solomon_forward_error_corrections_total"),
+		"The number of Reed–Solomon Forward Error Corrections",
+		[]string{"end"}, nil,
+	)
+)
+
+func collectV2(ch chan<- prometheus.Metric, status vigorv5.Status) {
+	direction := func(desc *prometheus.Desc, down float64, up float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, down, "down")
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, up, "up")
+	}
+	end := func(desc *prometheus.Desc, valueType prometheus.ValueType, near float64, far float64) {
+		ch <- prometheus.MustNewConstMetric(desc, valueType, near, "near")
+		ch <- prometheus.MustNewConstMetric(desc, valueType, far, "far")
+	}
+
+	direction(dslActualRateDesc, float64(status.ActualRateDownstream), float64(status.ActualRateUpstream))
+	direction(dslAttainableRateDesc, float64(status.AttainableRateDownstream), float64(status.AttainableRateUpstream))
+	direction(dslInterleaveDepthDesc, float64(status.InterleaveDepthDownstream), float64(status.InterleaveDepthUpstream))
+	direction(dslActualPsdDesc, status.ActualPSDDownstream, status.ActualPSDUpstream)
+	direction(dslSnrMarginDesc, status.SNRMarginDownstream, status.SNRMarginUpstream)
+
+	end(dslBitswapActiveDesc, prometheus.GaugeValue, optionToFloat64(status.BitswapNearEnd), optionToFloat64(status.BitswapFarEnd))
+	end(dslReTxActiveDesc, prometheus.GaugeValue, optionToFloat64(status.ReTxNearEnd), optionToFloat64(status.ReTxFarEnd))
+	end(dslAttenuationDesc, prometheus.GaugeValue, status.AttenuationNearEnd, status.AttenuationFarEnd)
+	end(dslCrcCountDesc, prometheus.CounterValue, float64(status.CrcNearEnd), float64(status.CrcFarEnd))
+	end(dslErroredSecondsDesc, prometheus.CounterValue, float64(status.EsNearEnd), float64(status.EsFarEnd))
+	end(dslSeverelyErroredSecondsDesc, prometheus.CounterValue, float64(status.SesNearEnd), float64(status.SesFarEnd))
+	end(dslUnavailableSecondsDesc, prometheus.CounterValue, float64(status.UasNearEnd), float64(status.UasFarEnd))
+	end(dslHecErrorCountDesc, prometheus.CounterValue, float64(status.HecErrorsNearEnd), float64(status.HecErrorsFarEnd))
+	end(dslLosFailureCountDesc, prometheus.CounterValue, float64(status.LosFailureNearEnd), float64(status.LosFailureFarEnd))
+	end(dslLofFailureCountDesc, prometheus.CounterValue, float64(status.LofFailureNearEnd), float64(status.LofFailureFarEnd))
+	end(dslLprFailureCountDesc, prometheus.CounterValue, float64(status.LprFailureNearEnd), float64(status.LprFailureFarEnd))
+	end(dslLcdFailureCountDesc, prometheus.CounterValue, float64(status.LcdFailureNearEnd), float64(status.LcdFailureFarEnd))
+	end(dslRfecCountDesc, prometheus.CounterValue, float64(status.RfecNearEnd), float64(status.RfecFarEnd))
+}
@@ -0,0 +1,376 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SuperQ/draytek_exporter/drayos"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/jpillora/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var errLoginPending = errors.New("initial login pending")
+
+var (
+	loginPerScrape     = kingpin.Flag("session.login-per-scrape", "Log in to the target before each scrape and log out afterwards, instead of keeping a session open").Default("false").Bool()
+	keepalive          = kingpin.Flag("session.keepalive", "Refresh the session on the target before it expires from being idle").Default("false").Bool()
+	sessionIdleTimeout = kingpin.Flag("session.idle-timeout", "Idle timeout of the session on the target, used for the keepalive when the target does not report it").Default("5m").Duration()
+)
+
+var (
+	sessionAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "session", "age_seconds"),
+		"The number of seconds since the exporter logged in to the device",
+		nil, nil,
+	)
+	sessionLoginsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "session", "logins_total"),
+		"The number of times the exporter logged in to the device",
+		nil, nil,
+	)
+	sessionReloginRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "session", "relogin_requests_total"),
+		"The number of requests that needed a new login because the session expired",
+		nil, nil,
+	)
+	sessionKeepalivesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "session", "keepalives_total"),
+		"The number of successful session keepalives sent to the device",
+		nil, nil,
+	)
+)
+
+// Loginer is implemented by device drivers that authenticate to the device.
+type Loginer interface {
+	Login() error
+}
+
+// Session is implemented by device drivers that hold a login session on the
+// device.
+type Session interface {
+	Loginer
+	Logout() error
+	SessionStart() time.Time
+	Logins() uint64
+	Relogins() uint64
+}
+
+// Keepaliver is implemented by device drivers whose session expires after an
+// idle timeout.
+type Keepaliver interface {
+	Keepalive() error
+	// IdleTimeout returns the idle timeout reported by the device, or 0 if
+	// it is unknown.
+	IdleTimeout() time.Duration
+	LastRequest() time.Time
+}
+
+// loginManager performs the initial login to the device in the background,
+// retrying with backoff until it succeeds. This lets the exporter start while
+// the device is still unreachable, for example after a power cut.
+//
+// In login per scrape mode, the exporter logs in before each scrape and logs
+// out afterwards, so it does not hold on to one of the admin sessions.
+type loginManager struct {
+	device    Loginer
+	logger    *slog.Logger
+	perScrape bool
+
+	// scrapeMtx serializes scrapes in login per scrape mode.
+	scrapeMtx sync.Mutex
+
+	mtx sync.RWMutex
+	// loggedIn is set once the initial login succeeded.
+	loggedIn bool
+	// err is the reason the device can not be scraped, from the initial
+	// login or from the last scrape.
+	err        error
+	keepalives uint64
+}
+
+func newLoginManager(device Loginer, logger *slog.Logger, perScrape bool) *loginManager {
+	return &loginManager{
+		device:    device,
+		logger:    logger,
+		perScrape: perScrape,
+		err:       errLoginPending,
+	}
+}
+
+// start runs the initial login, unless a login already succeeded, and the
+// keepalive if enabled, in the background until ctx is done. A nil
+// loginManager does nothing.
+func (m *loginManager) start(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	m.mtx.RLock()
+	loggedIn := m.loggedIn
+	m.mtx.RUnlock()
+	if !loggedIn {
+		go m.run(ctx)
+	}
+	if *keepalive {
+		go m.keepalive(ctx, *sessionIdleTimeout)
+	}
+}
+
+// run logs in to the device, retrying until it succeeds or ctx is done.
+func (m *loginManager) run(ctx context.Context) {
+	b := &backoff.Backoff{
+		Min:    time.Second,
+		Max:    5 * time.Minute,
+		Factor: 2,
+		Jitter: true,
+	}
+	for {
+		err := m.device.Login()
+		if err == nil {
+			m.setLoggedIn()
+			m.logger.Info("Initial Login on DrayTek device successful")
+			if m.perScrape {
+				m.logout()
+			}
+			return
+		}
+		m.setErr(err)
+
+		retry := b.Duration()
+		m.logger.Warn("Failed initial login attempt, retrying", "err", err, "retry_in", retry)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// loginOnce logs in to the device without retrying, for modes that only
+// scrape once.
+func (m *loginManager) loginOnce() {
+	if m == nil {
+		return
+	}
+	err := m.device.Login()
+	if err != nil {
+		m.setErr(err)
+		m.logger.Warn("Failed to login to DrayTek device", "err", err)
+		return
+	}
+	m.setLoggedIn()
+	if m.perScrape {
+		m.logout()
+	}
+}
+
+// keepalive refreshes the session on the device shortly before it would
+// expire from being idle. The idle timeout reported by the device is used if
+// known, otherwise idleTimeout.
+func (m *loginManager) keepalive(ctx context.Context, idleTimeout time.Duration) {
+	k, ok := m.device.(Keepaliver)
+	if !ok || m.perScrape {
+		return
+	}
+	for {
+		timeout := k.IdleTimeout()
+		if timeout <= 0 {
+			timeout = idleTimeout
+		}
+		// Refresh the session once 90% of the idle timeout has passed.
+		wait := max(time.Until(k.LastRequest().Add(timeout-timeout/10)), time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if m.Err() != nil || time.Since(k.LastRequest()) < timeout-timeout/10 {
+			continue
+		}
+		if err := k.Keepalive(); err != nil {
+			m.logger.Warn("Failed to send session keepalive", "err", err)
+			continue
+		}
+		m.mtx.Lock()
+		m.keepalives++
+		m.mtx.Unlock()
+	}
+}
+
+// acquire prepares the device for a scrape, logging in first in login per
+// scrape mode. Every call must be followed by a call to release.
+func (m *loginManager) acquire() error {
+	if m == nil {
+		return nil
+	}
+	if !m.perScrape {
+		m.mtx.RLock()
+		defer m.mtx.RUnlock()
+		if !m.loggedIn {
+			return m.err
+		}
+		return nil
+	}
+	m.scrapeMtx.Lock()
+	if err := m.Err(); errors.Is(err, errLoginPending) {
+		return err
+	}
+	err := m.device.Login()
+	m.setErr(err)
+	return err
+}
+
+// release ends a scrape, logging out in login per scrape mode.
+func (m *loginManager) release() {
+	if m == nil || !m.perScrape {
+		return
+	}
+	m.logout()
+	m.scrapeMtx.Unlock()
+}
+
+// logout ends the session on the device, if the driver supports it.
+func (m *loginManager) logout() {
+	if m == nil {
+		return
+	}
+	s, ok := m.device.(Session)
+	if !ok {
+		return
+	}
+	if err := s.Logout(); err != nil {
+		m.logger.Warn("Failed to log out of DrayTek device", "err", err)
+		return
+	}
+	m.logger.Debug("Logged out of DrayTek device")
+}
+
+// Describe implements the prometheus.Collector interface.
+func (m *loginManager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sessionAgeDesc
+	ch <- sessionLoginsDesc
+	ch <- sessionReloginRequestsDesc
+	ch <- sessionKeepalivesDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (m *loginManager) Collect(ch chan<- prometheus.Metric) {
+	s, ok := m.device.(Session)
+	if !ok {
+		return
+	}
+	if start := s.SessionStart(); !start.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			sessionAgeDesc, prometheus.GaugeValue, time.Since(start).Seconds(),
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		sessionLoginsDesc, prometheus.CounterValue, float64(s.Logins()),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		sessionReloginRequestsDesc, prometheus.CounterValue, float64(s.Relogins()),
+	)
+	if _, ok := m.device.(Keepaliver); ok {
+		m.mtx.RLock()
+		keepalives := m.keepalives
+		m.mtx.RUnlock()
+		ch <- prometheus.MustNewConstMetric(
+			sessionKeepalivesDesc, prometheus.CounterValue, float64(keepalives),
+		)
+	}
+}
+
+// Err returns the reason the device can not be scraped: the error of the
+// initial login until it succeeded, afterwards the error of the last scrape,
+// including failed relogins. A nil loginManager is always logged in.
+func (m *loginManager) Err() error {
+	if m == nil {
+		return nil
+	}
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.err
+}
+
+func (m *loginManager) setErr(err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.err = err
+}
+
+func (m *loginManager) setLoggedIn() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.loggedIn = true
+	m.err = nil
+}
+
+// scraped records the result of a scrape, nil if the device could be
+// scraped, so that relogin and fetch errors are reflected by Err.
+func (m *loginManager) scraped(err error) {
+	if m == nil {
+		return
+	}
+	m.setErr(err)
+}
+
+// downReason classifies why the device could not be scraped.
+func downReason(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errLoginPending):
+		return "login_pending"
+	case errors.Is(err, vigorv5.ErrLoginFailed), errors.Is(err, drayos.ErrLoginFailed):
+		return "login_failed"
+	case errors.Is(err, vigorv5.ErrCredentialsFailed), errors.Is(err, drayos.ErrCredentialsFailed):
+		return "credentials_failed"
+	case errors.As(err, &netErr):
+		return "unreachable"
+	default:
+		return "fetch_failed"
+	}
+}
+
+// healthyHandler reports that the exporter is running. It does not depend on
+// the device, so an unreachable device does not cause the exporter to be
+// restarted.
+func healthyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "DrayTek Exporter is Healthy.")
+	})
+}
+
+// readyHandler reports whether the exporter has logged in to the device and
+// the last scrape succeeded.
+func readyHandler(m *loginManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := m.Err(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "DrayTek Exporter is not Ready: %s: %v\n", downReason(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "DrayTek Exporter is Ready.")
+	})
+}
@@ -0,0 +1,156 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SuperQ/draytek_exporter/drayos"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeLoginer logs in successfully.
+type fakeLoginer struct{}
+
+func (fakeLoginer) Login() error { return nil }
+
+func ready(t *testing.T, m *loginManager) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	readyHandler(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestLoginManagerScrapeErrors(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	m := newLoginManager(fakeLoginer{}, logger, false)
+
+	if code, _ := ready(t, m); code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d before the initial login, want %d", code, http.StatusServiceUnavailable)
+	}
+	m.loginOnce()
+	if code, _ := ready(t, m); code != http.StatusOK {
+		t.Errorf("got status %d after the initial login, want %d", code, http.StatusOK)
+	}
+
+	// The router rejects the credentials on a relogin.
+	var scrapeErr error
+	d := &DrayTekCollector{
+		login:  m,
+		logger: logger,
+		collectors: map[string]Collector{
+			"dsl": collectorFunc(func(ch chan<- prometheus.Metric) error { return scrapeErr }),
+		},
+	}
+	scrapeErr = fmt.Errorf("relogin: %w", vigorv5.ErrLoginFailed)
+	want := `
+# HELP draytek_down_info The reason the draytek instance status was not successful
+# TYPE draytek_down_info gauge
+draytek_down_info{reason="login_failed"} 1
+`
+	if err := testutil.CollectAndCompare(d, strings.NewReader(want), "draytek_down_info"); err != nil {
+		t.Error(err)
+	}
+	code, body := ready(t, m)
+	if code != http.StatusServiceUnavailable || !strings.Contains(body, "login_failed") {
+		t.Errorf("got status %d %q after a rejected relogin, want %d login_failed", code, body, http.StatusServiceUnavailable)
+	}
+
+	// The collectors still run, and a successful scrape clears the error.
+	scrapeErr = nil
+	if n := testutil.CollectAndCount(d, "draytek_up"); n != 1 {
+		t.Errorf("got %d draytek_up metrics, want 1", n)
+	}
+	if code, _ := ready(t, m); code != http.StatusOK {
+		t.Errorf("got status %d after a successful scrape, want %d", code, http.StatusOK)
+	}
+}
+
+func TestDownReason(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{errLoginPending, "login_pending"},
+		{fmt.Errorf("relogin: %w", vigorv5.ErrLoginFailed), "login_failed"},
+		{drayos.ErrLoginFailed, "login_failed"},
+		{fmt.Errorf("%w: helper timed out", vigorv5.ErrCredentialsFailed), "credentials_failed"},
+		{fmt.Errorf("%w: helper timed out", drayos.ErrCredentialsFailed), "credentials_failed"},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, "unreachable"},
+		{vigorv5.ErrParseFailed, "fetch_failed"},
+	} {
+		if got := downReason(tc.err); got != tc.want {
+			t.Errorf("downReason(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+// fakeKeepaliver is a device whose session is always due for a keepalive.
+type fakeKeepaliver struct {
+	fakeLoginer
+	err   error
+	calls atomic.Int64
+}
+
+func (k *fakeKeepaliver) Keepalive() error {
+	k.calls.Add(1)
+	return k.err
+}
+
+func (k *fakeKeepaliver) IdleTimeout() time.Duration { return time.Millisecond }
+
+func (k *fakeKeepaliver) LastRequest() time.Time { return time.Time{} }
+
+func TestKeepaliveCountsSuccess(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ok := &fakeKeepaliver{}
+	failing := &fakeKeepaliver{err: vigorv5.ErrRequestFailed}
+	okManager, failingManager := newLoginManager(ok, logger, false), newLoginManager(failing, logger, false)
+	for _, m := range []*loginManager{okManager, failingManager} {
+		m.loginOnce()
+		go m.keepalive(ctx, time.Minute)
+	}
+	keepalives := func(m *loginManager) uint64 {
+		m.mtx.RLock()
+		defer m.mtx.RUnlock()
+		return m.keepalives
+	}
+
+	// The second attempt starts after the first failure was handled.
+	deadline := time.Now().Add(10 * time.Second)
+	for keepalives(okManager) == 0 || failing.calls.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("keepalives not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := keepalives(failingManager); got != 0 {
+		t.Errorf("got %d keepalives counted after failures, want 0", got)
+	}
+}
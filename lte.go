@@ -0,0 +1,119 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"log/slog"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("lte", defaultDisabled, NewLTECollector)
+}
+
+// LTECollector collects LTE modem signal stats from Vigor LTE models.
+type LTECollector struct {
+	v      *vigorv5.Vigor
+	logger *slog.Logger
+}
+
+// NewLTECollector returns an initialized LTECollector.
+func NewLTECollector(device StatusFetcher, modules []config.Module, logger *slog.Logger) (Collector, error) {
+	v, ok := device.(*vigorv5.Vigor)
+	if !ok {
+		return nil, ErrUnsupportedDevice
+	}
+	return &LTECollector{v: v, logger: logger}, nil
+}
+
+var (
+	lteInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lte", "info"),
+		"Info about the LTE connection",
+		[]string{"status", "sim_state", "operator", "mode", "band", "cell_id"}, nil,
+	)
+	lteSIMReadyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lte", "sim_ready"),
+		"Whether the SIM card is ready",
+		nil, nil,
+	)
+	lteRSRPDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lte", "rsrp_dbm"),
+		"The Reference Signal Received Power in dBm",
+		nil, nil,
+	)
+	lteRSRQDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lte", "rsrq_db"),
+		"The Reference Signal Received Quality in dB",
+		nil, nil,
+	)
+	lteSINRDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lte", "sinr_db"),
+		"The Signal to Interference plus Noise Ratio in dB",
+		nil, nil,
+	)
+	lteRSSIDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lte", "rssi_dbm"),
+		"The Received Signal Strength Indicator in dBm",
+		nil, nil,
+	)
+	lteTxBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lte", "transmit_bytes_total"),
+		"The number of bytes transmitted over the LTE connection",
+		nil, nil,
+	)
+	lteRxBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lte", "receive_bytes_total"),
+		"The number of bytes received over the LTE connection",
+		nil, nil,
+	)
+)
+
+// Update fetches the LTE stats from the draytek router and delivers them as
+// Prometheus metrics.
+func (c *LTECollector) Update(ch chan<- prometheus.Metric) error {
+	status, err := c.v.FetchLTEStatus()
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		lteInfoDesc, prometheus.GaugeValue, 1.0,
+		status.Status, status.SIMState, status.Operator, status.Mode, status.Band, status.CellID,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lteSIMReadyDesc, prometheus.GaugeValue, optionToFloat64(status.SIMState == "Ready"),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lteRSRPDesc, prometheus.GaugeValue, status.RSRP,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lteRSRQDesc, prometheus.GaugeValue, status.RSRQ,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lteSINRDesc, prometheus.GaugeValue, status.SINR,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lteRSSIDesc, prometheus.GaugeValue, status.RSSI,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lteTxBytesDesc, prometheus.CounterValue, float64(status.TxBytes),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lteRxBytesDesc, prometheus.CounterValue, float64(status.RxBytes),
+	)
+	return nil
+}
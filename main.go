@@ -15,10 +15,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/SuperQ/draytek_exporter/config"
+	"github.com/SuperQ/draytek_exporter/cwmp"
+	"github.com/SuperQ/draytek_exporter/detect"
+	"github.com/SuperQ/draytek_exporter/drayos"
+	"github.com/SuperQ/draytek_exporter/snmp"
+	"github.com/SuperQ/draytek_exporter/syslog"
 	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus/client_golang/prometheus"
@@ -31,7 +42,10 @@ import (
 	"github.com/prometheus/exporter-toolkit/web/kingpinflag"
 )
 
-const exporterName = "draytek_exporter"
+const (
+	exporterName    = "draytek_exporter"
+	shutdownTimeout = 5 * time.Second
+)
 
 func init() {
 	prometheus.MustRegister(versioncollector.NewCollector(exporterName))
@@ -39,54 +53,328 @@ func init() {
 
 func main() {
 	var (
+		_            = kingpin.Command("serve", "Run the exporter").Default()
 		toolkitFlags = kingpinflag.AddFlags(kingpin.CommandLine, ":9103")
 		metricsPath  = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
+		configFile   = kingpin.Flag("config.file", "Path to the configuration file with custom modules").Default("").String()
 
 		username    = kingpin.Flag("username", "username to authenticate to the target").Default("monitor").String()
 		passwordEnv = kingpin.Flag("password-env", "Env var that contains password to authenticate to the target").Default("DRAYTEK_PASSWORD").String()
 		target      = kingpin.Flag("target", "target host/ip the router/modem is reachable on").Default("192.168.1.1").String()
+		driver      = kingpin.Flag("driver", "API used to fetch the DSL status from the target, auto detects the web API").Default("auto").Enum("auto", detect.APIVigorV5, detect.APIDrayOSREST, "snmp")
+
+		detectTimeout = kingpin.Flag("detect.timeout", "Timeout for each request when detecting the device API").Default("5s").Duration()
+
+		snmpConfig = snmp.Config{}
+
+		syslogUDPAddress = kingpin.Flag("syslog.listen-address.udp", "Address to receive syslog messages over UDP on, empty to disable").Default("").String()
+		syslogTCPAddress = kingpin.Flag("syslog.listen-address.tcp", "Address to receive syslog messages over TCP on, empty to disable").Default("").String()
+		syslogBufferSize = kingpin.Flag("syslog.buffer-size", "Number of recent syslog events to keep").Default("100").Int()
+		syslogEventsPath = kingpin.Flag("syslog.events-path", "Path under which to expose recent syslog events as JSON").Default("/syslog/events").String()
+
+		cwmpPath        = kingpin.Flag("cwmp.path", "Path under which to accept TR-069 CWMP Informs, empty to disable").Default("").String()
+		cwmpUsername    = kingpin.Flag("cwmp.username", "Username CPEs must use to authenticate to the CWMP endpoint").Default("").String()
+		cwmpNoAuth      = kingpin.Flag("cwmp.allow-unauthenticated", "Accept CWMP Informs without authentication").Default("false").Bool()
+		cwmpPasswordEnv = kingpin.Flag("cwmp.password-env", "Env var that contains the password CPEs must use to authenticate to the CWMP endpoint").Default("DRAYTEK_CWMP_PASSWORD").String()
 	)
+	kingpin.Flag("snmp.port", "SNMP port of the target").Default("161").Uint16Var(&snmpConfig.Port)
+	kingpin.Flag("snmp.timeout", "Timeout for each SNMP request").Default("5s").DurationVar(&snmpConfig.Timeout)
+	kingpin.Flag("snmp.retries", "Number of SNMP request retries").Default("3").IntVar(&snmpConfig.Retries)
+	kingpin.Flag("snmp.version", "SNMP version").Default("2c").EnumVar(&snmpConfig.Version, "2c", "3")
+	kingpin.Flag("snmp.community", "SNMP v2c community").Default("public").StringVar(&snmpConfig.Community)
+	kingpin.Flag("snmp.username", "SNMP v3 username").Default("").StringVar(&snmpConfig.Username)
+	kingpin.Flag("snmp.security-level", "SNMP v3 security level").Default("noAuthNoPriv").EnumVar(&snmpConfig.SecurityLevel, "noAuthNoPriv", "authNoPriv", "authPriv")
+	kingpin.Flag("snmp.auth-protocol", "SNMP v3 authentication protocol").Default("SHA").EnumVar(&snmpConfig.AuthProtocol, "MD5", "SHA", "SHA224", "SHA256", "SHA384", "SHA512")
+	kingpin.Flag("snmp.priv-protocol", "SNMP v3 privacy protocol").Default("AES").EnumVar(&snmpConfig.PrivProtocol, "DES", "AES", "AES192", "AES256")
+	snmpAuthPasswordEnv := kingpin.Flag("snmp.auth-password-env", "Env var that contains the SNMP v3 authentication password").Default("DRAYTEK_SNMP_AUTH_PASSWORD").String()
+	snmpPrivPasswordEnv := kingpin.Flag("snmp.priv-password-env", "Env var that contains the SNMP v3 privacy password").Default("DRAYTEK_SNMP_PRIV_PASSWORD").String()
+
 	promslogConfig := &promslog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promslogConfig)
 	kingpin.Version(version.Print("mysqld_exporter"))
-	kingpin.Parse()
+	command := kingpin.Parse()
 	logger := promslog.New(promslogConfig)
 
-	logger.Info("Starting "+exporterName, "version", version.Info())
-	logger.Info("Build context", "build_context", version.BuildContext())
+	switch command {
+	case queryCmd.FullCommand():
+		username, password, err := loadCredentials(*target, *username, *passwordEnv)
+		var opts []vigorv5.Option
+		if err == nil {
+			opts, err = vigorOptions()
+		}
+		if err == nil {
+			var v *vigorv5.Vigor
+			v, err = vigorv5.New(logger, *target, username, password, opts...)
+			if err == nil {
+				err = runQuery(v, logger)
+			}
+		}
+		if err != nil {
+			logger.Error("Query failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	case supportCmd.FullCommand():
+		var modules []config.Module
+		username, password, err := loadCredentials(*target, *username, *passwordEnv)
+		if err == nil && *configFile != "" {
+			var cfg *config.Config
+			cfg, err = config.Load(*configFile)
+			if cfg != nil {
+				modules = cfg.Modules
+			}
+		}
+		if err == nil {
+			err = runSupportBundle(*target, *driver, username, password, *detectTimeout, modules)
+		}
+		if err != nil {
+			logger.Error("Unable to write support bundle", "err", err)
+			os.Exit(1)
+		}
+		logger.Info("Wrote support bundle", "file", *supportOutput)
+		return
+	case encodeCmd.FullCommand():
+		if err := runEncode(); err != nil {
+			logger.Error("Encode failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	case decodeCmd.FullCommand():
+		if err := runDecode(); err != nil {
+			logger.Error("Decode failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	password := os.Getenv(*passwordEnv)
-	if password == "" {
-		logger.Error("Missing password from env", "env", *passwordEnv)
-		os.Exit(1)
+	// Only the plugin output is expected from the check command, and setup
+	// errors are reported as UNKNOWN.
+	exitCode := 1
+	if command == checkCmd.FullCommand() {
+		exitCode = checkUnknown
+	} else {
+		logger.Info("Starting "+exporterName, "version", version.Info())
+		logger.Info("Build context", "build_context", version.BuildContext())
 	}
 
-	var err error
-	v, err := vigorv5.New(logger, *target, *username, password)
-	if err != nil {
-		logger.Error("Unable to create target", "err", err)
-		os.Exit(1)
+	var targets []config.Target
+	if *configFile != "" {
+		cfg, err := config.Load(*configFile)
+		if err != nil {
+			logger.Error("Error loading config", "err", err)
+			os.Exit(exitCode)
+		}
+		setCustomModules(cfg.Modules)
+		targets = cfg.Targets
 	}
 
-	err = v.Login()
-	if err != nil {
-		logger.Error("Failed initial login attempt", "err", err)
-		os.Exit(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		fetcher StatusFetcher
+		login   *loginManager
+		err     error
+	)
+	api := *driver
+	if len(targets) > 0 {
+		// The targets from the config file replace the --target flag.
+		api = ""
+	}
+	if *replayDir != "" {
+		api = detect.APIVigorV5
+	}
+	detector := detect.New(logger, *detectTimeout, *drayosInsecureSkipVerify)
+	if api == "auto" {
+		// The API is reported as unknown if it is guessed, a restart detects
+		// it again.
+		detected := "unknown"
+		r, err := detector.Detect(*target)
+		if err != nil {
+			api = detect.APIVigorV5
+			logger.Warn("Unable to detect device API, assuming "+api, "err", err)
+		} else {
+			api, detected = r.API, r.API
+		}
+		prometheus.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "device",
+				Name:        "api_info",
+				Help:        "The API detected on the draytek device",
+				ConstLabels: prometheus.Labels{"api": detected, "server": r.Server},
+			},
+			func() float64 { return 1 },
+		))
+	}
+
+	switch api {
+	case "":
+		// Only the targets from the config file are scraped, on /probe.
+	case detect.APIVigorV5:
+		credentials := flagCredentials(*target, *username)
+		password := os.Getenv(*passwordEnv)
+		if credentials == nil && password == "" && *replayDir == "" {
+			logger.Error("Missing password from env", "env", *passwordEnv)
+			os.Exit(exitCode)
+		}
+
+		opts, err := vigorOptions()
+		if err != nil {
+			logger.Error("Unable to set up the Vigor v5 client", "err", err)
+			os.Exit(exitCode)
+		}
+		if credentials != nil {
+			opts = append(opts, vigorv5.WithCredentials(credentials))
+		}
+		v, err := vigorv5.New(logger, *target, *username, password, opts...)
+		if err != nil {
+			logger.Error("Unable to create target", "err", err)
+			os.Exit(exitCode)
+		}
+
+		login = newLoginManager(v, logger, *loginPerScrape)
+		fetcher = v
+	case detect.APIDrayOSREST:
+		credentials := flagCredentials(*target, *username)
+		password := os.Getenv(*passwordEnv)
+		if credentials == nil && password == "" {
+			logger.Error("Missing password from env", "env", *passwordEnv)
+			os.Exit(exitCode)
+		}
+
+		var opts []drayos.Option
+		if credentials != nil {
+			opts = append(opts, drayos.WithCredentials(credentials))
+		}
+		c, err := drayos.New(logger, *drayosScheme+"://"+*target, *username, password, *drayosInsecureSkipVerify, opts...)
+		if err != nil {
+			logger.Error("Unable to create target", "err", err)
+			os.Exit(exitCode)
+		}
+
+		login = newLoginManager(c, logger, *loginPerScrape)
+		fetcher = c
+	case "snmp":
+		snmpConfig.AuthPassword = os.Getenv(*snmpAuthPasswordEnv)
+		snmpConfig.PrivPassword = os.Getenv(*snmpPrivPasswordEnv)
+		fetcher, err = snmp.New(logger, *target, snmpConfig)
+		if err != nil {
+			logger.Error("Unable to create target", "err", err)
+			os.Exit(exitCode)
+		}
+	case detect.APILegacyHTML:
+		logger.Error("Models with the legacy HTML web UI are not supported, use --driver=snmp", "target", *target)
+		os.Exit(exitCode)
+	default:
+		logger.Error("Unsupported device API", "api", api)
+		os.Exit(exitCode)
+	}
+
+	if command == checkCmd.FullCommand() {
+		code := runCheck(fetcher, login)
+		login.logout()
+		os.Exit(code)
+	}
+
+	if *textfilePath != "" {
+		if fetcher == nil {
+			logger.Error("Textfile output requires a single --target")
+			os.Exit(1)
+		}
+		err := runTextfile(ctx, fetcher, login, logger)
+		login.logout()
+		if err != nil {
+			logger.Error("Unable to write textfile", "file", *textfilePath, "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if login != nil {
+		prometheus.MustRegister(login)
+		login.start(ctx)
 	}
-	logger.Info("Initial Login on DrayTek device successful")
 
 	http.Handle(*metricsPath, promhttp.Handler())
+	http.Handle("/-/healthy", healthyHandler())
+	http.Handle("/-/ready", readyHandler(login))
+	landingLinks := []web.LandingLinks{
+		{
+			Address: *metricsPath,
+			Text:    "Metrics",
+		},
+	}
+
+	targetManager := newTargetManager(logger, detector)
+	reloader := newConfigReloader(*configFile, targetManager, logger)
+	prometheus.MustRegister(reloader)
+	http.Handle("/-/reload", reloader.handler(ctx))
+	if *configFile != "" {
+		if err := targetManager.update(ctx, targets, getCustomModules()); err != nil {
+			logger.Error("Unable to create targets", "err", err)
+			os.Exit(1)
+		}
+		http.Handle("/probe", targetManager)
+		landingLinks = append(landingLinks, web.LandingLinks{
+			Address: "/probe",
+			Text:    "Probe",
+		})
+	}
+
+	if *syslogUDPAddress != "" || *syslogTCPAddress != "" {
+		if *syslogBufferSize < 0 {
+			logger.Error("Syslog buffer size must not be negative", "size", *syslogBufferSize)
+			os.Exit(exitCode)
+		}
+		l := syslog.New(logger, *syslogBufferSize)
+		prometheus.MustRegister(l)
+		http.Handle(*syslogEventsPath, l)
+		landingLinks = append(landingLinks, web.LandingLinks{
+			Address: *syslogEventsPath,
+			Text:    "Syslog Events",
+		})
+
+		if *syslogUDPAddress != "" {
+			go func() {
+				if err := l.ListenUDP(*syslogUDPAddress); err != nil {
+					logger.Error("Error receiving syslog", "protocol", "udp", "err", err)
+					os.Exit(1)
+				}
+			}()
+		}
+		if *syslogTCPAddress != "" {
+			go func() {
+				if err := l.ListenTCP(*syslogTCPAddress); err != nil {
+					logger.Error("Error receiving syslog", "protocol", "tcp", "err", err)
+					os.Exit(1)
+				}
+			}()
+		}
+	}
+
+	if *cwmpPath != "" {
+		cwmpPassword := os.Getenv(*cwmpPasswordEnv)
+		if (*cwmpUsername == "" || cwmpPassword == "") && !*cwmpNoAuth {
+			logger.Error("The CWMP endpoint requires --cwmp.username and a password, or --cwmp.allow-unauthenticated", "env", *cwmpPasswordEnv)
+			os.Exit(exitCode)
+		}
+		s := cwmp.New(logger, *cwmpUsername, cwmpPassword)
+		http.Handle(*cwmpPath, s)
+		http.Handle(*cwmpPath+"/probe", cwmpProbeHandler(s, logger))
+		http.Handle(*cwmpPath+"/targets", cwmpTargetsHandler(s))
+		landingLinks = append(landingLinks, web.LandingLinks{
+			Address: *cwmpPath + "/targets",
+			Text:    "CWMP Targets",
+		})
+	}
+
 	if *metricsPath != "/" && *metricsPath != "" {
 		landingConfig := web.LandingConfig{
 			Name:        "DrayTek Exporter",
 			Description: "Prometheus Exporter for DrayTek modems/routers",
 			Version:     version.Info(),
-			Links: []web.LandingLinks{
-				{
-					Address: *metricsPath,
-					Text:    "Metrics",
-				},
-			},
+			Links:       landingLinks,
 		}
 		landingPage, err := web.NewLandingPage(landingConfig)
 		if err != nil {
@@ -96,11 +384,44 @@ func main() {
 		http.Handle("/", landingPage)
 	}
 
-	prometheus.MustRegister(NewExporter(v))
+	if fetcher != nil {
+		collector, err := NewDrayTekCollector(fetcher, login, getCustomModules(), logger)
+		if err != nil {
+			logger.Error("Unable to create collectors", "err", err)
+			os.Exit(1)
+		}
+		prometheus.MustRegister(collector)
+		reloader.collector = collector
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				_ = reloader.reload(ctx)
+			}
+		}
+	}()
 
 	srv := &http.Server{}
-	if err := web.ListenAndServe(srv, toolkitFlags, logger); err != nil {
-		logger.Error("Error starting HTTP server", "err", err)
-		os.Exit(1)
+	go func() {
+		if err := web.ListenAndServe(srv, toolkitFlags, logger); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Error starting HTTP server", "err", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Error shutting down HTTP server", "err", err)
 	}
+	login.logout()
+	targetManager.stop()
 }
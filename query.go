@@ -0,0 +1,100 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/alecthomas/kingpin/v2"
+)
+
+var (
+	queryCmd = kingpin.Command("query", "Send a raw pid/op/ct request to the target and print the decoded JSON response")
+	queryPID = queryCmd.Arg("pid", "pid of the page to request, for example 0MONITORING_DSL_GENERAL").Required().String()
+	queryOp  = queryCmd.Flag("op", "op of the request").Default("501").String()
+	queryCT  = queryCmd.Flag("ct", "ct JSON of the request").Default(`{"param":[],"ct":[]}`).String()
+
+	encodeCmd  = kingpin.Command("encode", "Encode JSON into the webproc.cgi payload format")
+	encodeJSON = encodeCmd.Arg("json", "JSON to encode, read from stdin if not set").String()
+
+	decodeCmd     = kingpin.Command("decode", "Decode a captured webproc.cgi payload into JSON")
+	decodePayload = decodeCmd.Arg("payload", "Payload to decode, read from stdin if not set").String()
+)
+
+// argOrStdin returns arg, or all of stdin if arg is empty.
+func argOrStdin(arg string) (string, error) {
+	if arg != "" {
+		return arg, nil
+	}
+	b, err := io.ReadAll(os.Stdin)
+	return strings.TrimSpace(string(b)), err
+}
+
+// printJSON pretty-prints j to stdout, or prints it as is if it is not valid
+// JSON.
+func printJSON(j string) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(j), "", "  "); err != nil {
+		fmt.Println(j)
+		return
+	}
+	fmt.Println(buf.String())
+}
+
+func runQuery(v *vigorv5.Vigor, logger *slog.Logger) error {
+	if err := v.Login(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := v.Logout(); err != nil {
+			logger.Warn("Failed to log out of DrayTek device", "err", err)
+		}
+	}()
+
+	resp, err := v.Query(*queryPID, *queryOp, *queryCT)
+	if err != nil {
+		return err
+	}
+	printJSON(resp)
+	return nil
+}
+
+func runEncode() error {
+	j, err := argOrStdin(*encodeJSON)
+	if err != nil {
+		return err
+	}
+	fmt.Println(vigorv5.Encode(j))
+	return nil
+}
+
+func runDecode() error {
+	payload, err := argOrStdin(*decodePayload)
+	if err != nil {
+		return err
+	}
+	j, err := vigorv5.Decode(payload)
+	if err != nil {
+		return err
+	}
+	printJSON(j)
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	configLastReloadSuccessfulDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "config_last_reload_successful"),
+		"Whether the last configuration reload attempt was successful",
+		nil, nil,
+	)
+	configLastReloadSuccessTimestampDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "config_last_reload_success_timestamp_seconds"),
+		"The time of the last successful configuration reload",
+		nil, nil,
+	)
+)
+
+// configReloader re-reads the config file on SIGHUP or POST /-/reload. Custom
+// modules are updated and targets are added, updated and removed, while the
+// sessions of unchanged targets are kept.
+type configReloader struct {
+	path    string
+	targets *targetManager
+	// collector is the collector of the --target flag, if it is used.
+	collector *DrayTekCollector
+	logger    *slog.Logger
+
+	// reloadMtx serializes reloads.
+	reloadMtx sync.Mutex
+
+	mtx         sync.Mutex
+	success     bool
+	successTime time.Time
+}
+
+func newConfigReloader(path string, targets *targetManager, logger *slog.Logger) *configReloader {
+	return &configReloader{
+		path:        path,
+		targets:     targets,
+		logger:      logger,
+		success:     true,
+		successTime: time.Now(),
+	}
+}
+
+// reload re-reads the config file. If it is invalid, the current config is
+// kept.
+func (r *configReloader) reload(ctx context.Context) error {
+	r.reloadMtx.Lock()
+	defer r.reloadMtx.Unlock()
+
+	err := r.apply(ctx)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.success = err == nil
+	if err != nil {
+		r.logger.Error("Error reloading config", "err", err)
+		return err
+	}
+	r.successTime = time.Now()
+	r.logger.Info("Reloaded config", "file", r.path)
+	return nil
+}
+
+func (r *configReloader) apply(ctx context.Context) error {
+	if r.path == "" {
+		return nil
+	}
+	cfg, err := config.Load(r.path)
+	if err != nil {
+		return err
+	}
+
+	// Everything is created before anything is changed, so that an error
+	// leaves the current config in place.
+	var collectors map[string]Collector
+	if r.collector != nil {
+		collectors, err = r.collector.prepareReload(cfg.Modules)
+		if err != nil {
+			return err
+		}
+	}
+	u, err := r.targets.prepare(ctx, cfg.Targets, cfg.Modules)
+	if err != nil {
+		return err
+	}
+
+	setCustomModules(cfg.Modules)
+	if r.collector != nil {
+		r.collector.setCollectors(collectors)
+	}
+	u.commit()
+	return nil
+}
+
+// handler reloads the config on POST requests.
+func (r *configReloader) handler(ctx context.Context) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.reload(ctx); err != nil {
+			http.Error(w, fmt.Sprintf("failed to reload config: %s", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(w, "Reloaded config.")
+	})
+}
+
+// Describe implements the prometheus.Collector interface.
+func (r *configReloader) Describe(ch chan<- *prometheus.Desc) {
+	ch <- configLastReloadSuccessfulDesc
+	ch <- configLastReloadSuccessTimestampDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (r *configReloader) Collect(ch chan<- prometheus.Metric) {
+	r.mtx.Lock()
+	success, successTime := r.success, r.successTime
+	r.mtx.Unlock()
+
+	var successValue float64
+	if success {
+		successValue = 1
+	}
+	ch <- prometheus.MustNewConstMetric(
+		configLastReloadSuccessfulDesc, prometheus.GaugeValue, successValue,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		configLastReloadSuccessTimestampDesc, prometheus.GaugeValue, float64(successTime.Unix()),
+	)
+}
@@ -0,0 +1,158 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	"github.com/SuperQ/draytek_exporter/cwmp"
+)
+
+const reloadConfig = `
+modules:
+  - name: %s
+    pid: 0MONITORING_DSL_GENERAL
+    metrics:
+      - name: dsl_trellis
+        path: 'ct.0.0MONITORING_DSL_GENERAL.#(Name=="Setting").Trellis'
+        parser: option
+`
+
+func TestReloadKeepsConfigOnError(t *testing.T) {
+	defer func(enabled bool, modules []config.Module) {
+		*collectorState["custom"] = enabled
+		setCustomModules(modules)
+	}(*collectorState["custom"], getCustomModules())
+	*collectorState["custom"] = true
+
+	logger := slog.New(slog.DiscardHandler)
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	moduleName := func(d *DrayTekCollector) string {
+		d.mtx.RLock()
+		defer d.mtx.RUnlock()
+		return d.collectors["custom"].(*CustomCollector).modules[0].Name
+	}
+
+	writeConfig(fmt.Sprintf(reloadConfig, "before"))
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setCustomModules(cfg.Modules)
+	collector, err := NewDrayTekCollector(replayVigor(t, "testdata/vigor_v5/custom_dsl"), nil, getCustomModules(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	targets := newTargetManager(logger, nil)
+	defer targets.stop()
+	r := newConfigReloader(path, targets, logger)
+	r.collector = collector
+
+	// The target has no credentials, so the whole reload fails.
+	writeConfig(fmt.Sprintf(reloadConfig, "after") + `
+targets:
+  - name: office
+    address: 192.0.2.1
+`)
+	if err := r.reload(ctx); err == nil {
+		t.Fatal("expected the reload to fail")
+	}
+	if got := moduleName(collector); got != "before" {
+		t.Errorf("got module %q in the collector after a failed reload, want before", got)
+	}
+	if got := getCustomModules()[0].Name; got != "before" {
+		t.Errorf("got custom module %q after a failed reload, want before", got)
+	}
+
+	writeConfig(fmt.Sprintf(reloadConfig, "after"))
+	if err := r.reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := moduleName(collector); got != "after" {
+		t.Errorf("got module %q in the collector after a reload, want after", got)
+	}
+	if got := getCustomModules()[0].Name; got != "after" {
+		t.Errorf("got custom module %q after a reload, want after", got)
+	}
+}
+
+func TestReloadConcurrentProbe(t *testing.T) {
+	defer func(enabled bool, modules []config.Module) {
+		*collectorState["custom"] = enabled
+		setCustomModules(modules)
+	}(*collectorState["custom"], getCustomModules())
+	*collectorState["custom"] = true
+
+	logger := slog.New(slog.DiscardHandler)
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(reloadConfig, "reload")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	inform, err := os.ReadFile("cwmp/testdata/inform.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := cwmp.New(logger, "", "")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cwmp", bytes.NewReader(inform)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("inform: got %d %q", w.Code, w.Body.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	targets := newTargetManager(logger, nil)
+	defer targets.stop()
+	r := newConfigReloader(path, targets, logger)
+	probe := cwmpProbeHandler(s, logger)
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for range 20 {
+			if err := r.reload(ctx); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	for range 4 {
+		wg.Go(func() {
+			for range 20 {
+				w := httptest.NewRecorder()
+				probe.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cwmp/probe?target=001DAA-2101234567890", nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("probe: got %d %q", w.Code, w.Body.String())
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,103 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"log/slog"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("sessions", defaultDisabled, NewSessionCollector)
+}
+
+// SessionCollector collects NAT session table usage and firewall stats.
+type SessionCollector struct {
+	v      *vigorv5.Vigor
+	logger *slog.Logger
+}
+
+// NewSessionCollector returns an initialized SessionCollector.
+func NewSessionCollector(device StatusFetcher, modules []config.Module, logger *slog.Logger) (Collector, error) {
+	v, ok := device.(*vigorv5.Vigor)
+	if !ok {
+		return nil, ErrUnsupportedDevice
+	}
+	return &SessionCollector{v: v, logger: logger}, nil
+}
+
+var (
+	natSessionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "nat", "sessions"),
+		"The number of active NAT sessions",
+		nil, nil,
+	)
+	natSessionsMaxDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "nat", "sessions_max"),
+		"The maximum number of NAT sessions supported",
+		nil, nil,
+	)
+	natProtocolSessionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "nat", "protocol_sessions"),
+		"The number of active NAT sessions by protocol",
+		[]string{"protocol"}, nil,
+	)
+	dosDefenseTriggersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "dos_defense", "triggers_total"),
+		"The number of times the DoS defence was triggered",
+		[]string{"type"}, nil,
+	)
+	filterBlockedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "filter", "blocked_total"),
+		"The number of packets or requests blocked by the filter",
+		[]string{"filter"}, nil,
+	)
+)
+
+// Update fetches the session stats from the draytek router and delivers them
+// as Prometheus metrics.
+func (c *SessionCollector) Update(ch chan<- prometheus.Metric) error {
+	status, err := c.v.FetchSessionStatus()
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		natSessionsDesc, prometheus.GaugeValue, float64(status.Sessions),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		natSessionsMaxDesc, prometheus.GaugeValue, float64(status.MaxSessions),
+	)
+	for protocol, sessions := range status.ProtocolSessions {
+		ch <- prometheus.MustNewConstMetric(
+			natProtocolSessionsDesc, prometheus.GaugeValue, float64(sessions),
+			protocol,
+		)
+	}
+	for dosType, count := range status.DoSDefense {
+		ch <- prometheus.MustNewConstMetric(
+			dosDefenseTriggersDesc, prometheus.CounterValue, float64(count),
+			dosType,
+		)
+	}
+	for filter, count := range status.Blocked {
+		ch <- prometheus.MustNewConstMetric(
+			filterBlockedDesc, prometheus.CounterValue, float64(count),
+			filter,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSessionCollector(t *testing.T) {
+	c := &SessionCollector{
+		v:      replayVigor(t, "testdata/vigor_v5/sessions"),
+		logger: slog.New(slog.DiscardHandler),
+	}
+
+	want := `
+# HELP draytek_dos_defense_triggers_total The number of times the DoS defence was triggered
+# TYPE draytek_dos_defense_triggers_total counter
+draytek_dos_defense_triggers_total{type="Port_Scan"} 0
+draytek_dos_defense_triggers_total{type="SYN_Flood"} 17
+# HELP draytek_filter_blocked_total The number of packets or requests blocked by the filter
+# TYPE draytek_filter_blocked_total counter
+draytek_filter_blocked_total{filter="content"} 90
+draytek_filter_blocked_total{filter="firewall"} 5678
+# HELP draytek_nat_protocol_sessions The number of active NAT sessions by protocol
+# TYPE draytek_nat_protocol_sessions gauge
+draytek_nat_protocol_sessions{protocol="icmp"} 4
+draytek_nat_protocol_sessions{protocol="tcp"} 1000
+draytek_nat_protocol_sessions{protocol="udp"} 230
+# HELP draytek_nat_sessions The number of active NAT sessions
+# TYPE draytek_nat_sessions gauge
+draytek_nat_sessions 1234
+# HELP draytek_nat_sessions_max The maximum number of NAT sessions supported
+# TYPE draytek_nat_sessions_max gauge
+draytek_nat_sessions_max 60000
+`
+	if err := testutil.CollectAndCompare(updateCollector{c}, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
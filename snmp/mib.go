@@ -0,0 +1,203 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package snmp
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	// IF-MIB
+	ifType = "1.3.6.1.2.1.2.2.1.3"
+
+	// ADSL-LINE-MIB
+	adslMibObjects = "1.3.6.1.2.1.10.94.1.1"
+
+	adslAtucCurrSnrMgn         = adslMibObjects + ".2.1.4"
+	adslAtucCurrAtn            = adslMibObjects + ".2.1.5"
+	adslAtucCurrAttainableRate = adslMibObjects + ".2.1.8"
+	adslAturCurrSnrMgn         = adslMibObjects + ".3.1.4"
+	adslAturCurrAtn            = adslMibObjects + ".3.1.5"
+	adslAturCurrAttainableRate = adslMibObjects + ".3.1.8"
+	adslAtucChanCurrTxRate     = adslMibObjects + ".4.1.2"
+	adslAturChanCurrTxRate     = adslMibObjects + ".5.1.2"
+	adslAtucPerfLofs           = adslMibObjects + ".6.1.1"
+	adslAtucPerfLoss           = adslMibObjects + ".6.1.2"
+	adslAtucPerfLprs           = adslMibObjects + ".6.1.4"
+	adslAtucPerfESs            = adslMibObjects + ".6.1.5"
+	adslAturPerfLofs           = adslMibObjects + ".7.1.1"
+	adslAturPerfLoss           = adslMibObjects + ".7.1.2"
+	adslAturPerfLprs           = adslMibObjects + ".7.1.3"
+	adslAturPerfESs            = adslMibObjects + ".7.1.4"
+	adslAtucChanCorrectedBlks  = adslMibObjects + ".10.1.3"
+	adslAtucChanUncorrectBlks  = adslMibObjects + ".10.1.4"
+	adslAturChanCorrectedBlks  = adslMibObjects + ".11.1.3"
+	adslAturChanUncorrectBlks  = adslMibObjects + ".11.1.4"
+
+	// ADSL-LINE-EXT-MIB
+	adslExtMibObjects   = "1.3.6.1.2.1.10.94.3.1"
+	adslAtucPerfStatSes = adslExtMibObjects + ".18.1.3"
+	adslAtucPerfStatUas = adslExtMibObjects + ".18.1.4"
+	adslAturPerfStatSes = adslExtMibObjects + ".20.1.1"
+	adslAturPerfStatUas = adslExtMibObjects + ".20.1.2"
+
+	// VDSL2-LINE-MIB
+	xdsl2Line                       = "1.3.6.1.2.1.10.251.1.1"
+	xdsl2LineStatusAttainableRateDs = xdsl2Line + ".1.1.20"
+	xdsl2LineStatusAttainableRateUs = xdsl2Line + ".1.1.21"
+	xdsl2LineStatusActPsdDs         = xdsl2Line + ".1.1.22"
+	xdsl2LineStatusActPsdUs         = xdsl2Line + ".1.1.23"
+	xdsl2LineBandStatusLnAtten      = xdsl2Line + ".2.1.2"
+	xdsl2LineBandStatusSnrMargin    = xdsl2Line + ".2.1.4"
+
+	xdsl2ChannelStatus       = "1.3.6.1.2.1.10.251.1.2.2"
+	xdsl2ChStatusActDataRate = xdsl2ChannelStatus + ".1.2"
+	xdsl2ChStatusIntlvDepth  = xdsl2ChannelStatus + ".1.10"
+
+	// xdsl2LineBand and xdsl2ChStatusUnit index values.
+	xdsl2BandUpstream   = "1"
+	xdsl2BandDownstream = "2"
+	xdsl2UnitXtuc       = "1"
+	xdsl2UnitXtur       = "2"
+)
+
+var walkRoots = []string{
+	ifType,
+	adslMibObjects,
+	adslExtMibObjects,
+	xdsl2Line,
+	xdsl2ChannelStatus,
+}
+
+// dslIfTypes are the IANAifType values of DSL interfaces.
+var dslIfTypes = []int{
+	94,  // adsl
+	97,  // vdsl
+	230, // adsl2
+	238, // adsl2plus
+	251, // vdsl2
+}
+
+type pduMap map[string]gosnmp.SnmpPDU
+
+func (m pduMap) int(oid string, index ...string) (int, bool) {
+	pdu, ok := m[strings.Join(append([]string{oid}, index...), ".")]
+	if !ok {
+		return 0, false
+	}
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return 0, false
+	}
+	return int(gosnmp.ToBigInt(pdu.Value).Int64()), true
+}
+
+// first returns the first value found in oids, preferring the order given.
+func (m pduMap) first(index []string, oids ...string) int {
+	for _, oid := range oids {
+		if x, ok := m.int(oid, index...); ok {
+			return x
+		}
+	}
+	return 0
+}
+
+// dslIfIndex returns the lowest ifIndex with a DSL ifType.
+func (m pduMap) dslIfIndex() (string, bool) {
+	var indexes []int
+	prefix := ifType + "."
+	for oid, pdu := range m {
+		index, ok := strings.CutPrefix(oid, prefix)
+		if !ok {
+			continue
+		}
+		if !slices.Contains(dslIfTypes, int(gosnmp.ToBigInt(pdu.Value).Int64())) {
+			continue
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	if len(indexes) == 0 {
+		return "", false
+	}
+	return strconv.Itoa(slices.Min(indexes)), true
+}
+
+// statusFromPDUs maps the walked MIBs into a vigorv5.Status. VDSL2-LINE-MIB
+// values are preferred over ADSL-LINE-MIB where both exist. The near end is
+// the CPE (ATU-R), the far end is the DSLAM (ATU-C).
+func statusFromPDUs(m pduMap) (vigorv5.Status, error) {
+	ifIndex, ok := m.dslIfIndex()
+	if !ok {
+		return vigorv5.Status{}, ErrNoDSLInterface
+	}
+	i := []string{ifIndex}
+	ds := []string{ifIndex, xdsl2BandDownstream}
+	us := []string{ifIndex, xdsl2BandUpstream}
+	xtur := []string{ifIndex, xdsl2UnitXtur}
+	xtuc := []string{ifIndex, xdsl2UnitXtuc}
+
+	firstOf := func(a []string, aOID string, b []string, bOID string) int {
+		if x, ok := m.int(aOID, a...); ok {
+			return x
+		}
+		return m.first(b, bOID)
+	}
+
+	return vigorv5.Status{
+		// The xTU-R receives the downstream, the xTU-C receives the upstream.
+		ActualRateDownstream:      firstOf(xtur, xdsl2ChStatusActDataRate, i, adslAtucChanCurrTxRate),
+		ActualRateUpstream:        firstOf(xtuc, xdsl2ChStatusActDataRate, i, adslAturChanCurrTxRate),
+		AttainableRateDownstream:  m.first(i, xdsl2LineStatusAttainableRateDs, adslAturCurrAttainableRate),
+		AttainableRateUpstream:    m.first(i, xdsl2LineStatusAttainableRateUs, adslAtucCurrAttainableRate),
+		InterleaveDepthDownstream: m.first(xtur, xdsl2ChStatusIntlvDepth),
+		InterleaveDepthUpstream:   m.first(xtuc, xdsl2ChStatusIntlvDepth),
+		ActualPSDDownstream:       tenth(m.first(i, xdsl2LineStatusActPsdDs)),
+		ActualPSDUpstream:         tenth(m.first(i, xdsl2LineStatusActPsdUs)),
+		SNRMarginDownstream:       tenth(firstOf(ds, xdsl2LineBandStatusSnrMargin, i, adslAturCurrSnrMgn)),
+		SNRMarginUpstream:         tenth(firstOf(us, xdsl2LineBandStatusSnrMargin, i, adslAtucCurrSnrMgn)),
+
+		AttenuationNearEnd: tenth(firstOf(ds, xdsl2LineBandStatusLnAtten, i, adslAturCurrAtn)),
+		AttenuationFarEnd:  tenth(firstOf(us, xdsl2LineBandStatusLnAtten, i, adslAtucCurrAtn)),
+		CrcNearEnd:         m.first(i, adslAturChanUncorrectBlks),
+		CrcFarEnd:          m.first(i, adslAtucChanUncorrectBlks),
+		EsNearEnd:          m.first(i, adslAturPerfESs),
+		EsFarEnd:           m.first(i, adslAtucPerfESs),
+		SesNearEnd:         m.first(i, adslAturPerfStatSes),
+		SesFarEnd:          m.first(i, adslAtucPerfStatSes),
+		UasNearEnd:         m.first(i, adslAturPerfStatUas),
+		UasFarEnd:          m.first(i, adslAtucPerfStatUas),
+		LosFailureNearEnd:  m.first(i, adslAturPerfLoss),
+		LosFailureFarEnd:   m.first(i, adslAtucPerfLoss),
+		LofFailureNearEnd:  m.first(i, adslAturPerfLofs),
+		LofFailureFarEnd:   m.first(i, adslAtucPerfLofs),
+		LprFailureNearEnd:  m.first(i, adslAturPerfLprs),
+		LprFailureFarEnd:   m.first(i, adslAtucPerfLprs),
+		RfecNearEnd:        m.first(i, adslAturChanCorrectedBlks),
+		RfecFarEnd:         m.first(i, adslAtucChanCorrectedBlks),
+	}, nil
+}
+
+// tenth converts a value in 0.1 units.
+func tenth(x int) float64 {
+	return float64(x) / 10
+}
@@ -0,0 +1,187 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snmp fetches DSL line status over SNMP as an alternative to the web
+// UI. It walks the standard ADSL-LINE-MIB (RFC 2662), ADSL-LINE-EXT-MIB
+// (RFC 3440), VDSL2-LINE-MIB (RFC 5650) and IF-MIB.
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/gosnmp/gosnmp"
+)
+
+var ErrNoDSLInterface = errors.New("no DSL interface found")
+
+// Config holds the SNMP connection settings.
+type Config struct {
+	Port    uint16
+	Timeout time.Duration
+	Retries int
+
+	// Version is either "2c" or "3".
+	Version   string
+	Community string
+
+	Username      string
+	SecurityLevel string
+	AuthProtocol  string
+	AuthPassword  string
+	PrivProtocol  string
+	PrivPassword  string
+}
+
+// Client fetches the DSL status of a single target.
+type Client struct {
+	logger *slog.Logger
+	target string
+	config Config
+}
+
+// New returns an SNMP client for the target host.
+func New(logger *slog.Logger, target string, config Config) (*Client, error) {
+	switch config.Version {
+	case "2c", "3":
+	default:
+		return nil, fmt.Errorf("unsupported SNMP version %q", config.Version)
+	}
+	return &Client{
+		logger: logger,
+		target: target,
+		config: config,
+	}, nil
+}
+
+func (c *Client) connect() (*gosnmp.GoSNMP, error) {
+	g := &gosnmp.GoSNMP{
+		Target:             c.target,
+		Port:               c.config.Port,
+		Transport:          "udp",
+		Timeout:            c.config.Timeout,
+		Retries:            c.config.Retries,
+		MaxOids:            gosnmp.MaxOids,
+		MaxRepetitions:     25,
+		ExponentialTimeout: true,
+	}
+
+	switch c.config.Version {
+	case "2c":
+		g.Version = gosnmp.Version2c
+		g.Community = c.config.Community
+	case "3":
+		g.Version = gosnmp.Version3
+		g.SecurityModel = gosnmp.UserSecurityModel
+
+		usm := &gosnmp.UsmSecurityParameters{
+			UserName:                 c.config.Username,
+			AuthenticationPassphrase: c.config.AuthPassword,
+			PrivacyPassphrase:        c.config.PrivPassword,
+		}
+		var err error
+		g.MsgFlags, err = securityLevel(c.config.SecurityLevel)
+		if err != nil {
+			return nil, err
+		}
+		usm.AuthenticationProtocol, err = authProtocol(c.config.AuthProtocol)
+		if err != nil {
+			return nil, err
+		}
+		usm.PrivacyProtocol, err = privProtocol(c.config.PrivProtocol)
+		if err != nil {
+			return nil, err
+		}
+		g.SecurityParameters = usm
+	}
+
+	return g, g.Connect()
+}
+
+// FetchStatus walks the DSL line MIBs and maps them into a vigorv5.Status.
+func (c *Client) FetchStatus() (vigorv5.Status, error) {
+	g, err := c.connect()
+	if err != nil {
+		c.logger.Debug("Unable to connect", "target", c.target, "err", err)
+		return vigorv5.Status{}, err
+	}
+	defer g.Conn.Close()
+
+	values := pduMap{}
+	for _, root := range walkRoots {
+		pdus, err := g.BulkWalkAll(root)
+		if err != nil {
+			c.logger.Debug("Walk failed", "target", c.target, "oid", root, "err", err)
+			return vigorv5.Status{}, err
+		}
+		for _, pdu := range pdus {
+			values[strings.TrimPrefix(pdu.Name, ".")] = pdu
+		}
+	}
+	c.logger.Debug("Walked DSL MIBs", "target", c.target, "oids", len(values))
+
+	return statusFromPDUs(values)
+}
+
+func securityLevel(level string) (gosnmp.SnmpV3MsgFlags, error) {
+	switch level {
+	case "", "noAuthNoPriv":
+		return gosnmp.NoAuthNoPriv, nil
+	case "authNoPriv":
+		return gosnmp.AuthNoPriv, nil
+	case "authPriv":
+		return gosnmp.AuthPriv, nil
+	}
+	return 0, fmt.Errorf("unsupported SNMP security level %q", level)
+}
+
+func authProtocol(protocol string) (gosnmp.SnmpV3AuthProtocol, error) {
+	switch protocol {
+	case "":
+		return gosnmp.NoAuth, nil
+	case "MD5":
+		return gosnmp.MD5, nil
+	case "SHA":
+		return gosnmp.SHA, nil
+	case "SHA224":
+		return gosnmp.SHA224, nil
+	case "SHA256":
+		return gosnmp.SHA256, nil
+	case "SHA384":
+		return gosnmp.SHA384, nil
+	case "SHA512":
+		return gosnmp.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported SNMP auth protocol %q", protocol)
+}
+
+func privProtocol(protocol string) (gosnmp.SnmpV3PrivProtocol, error) {
+	switch protocol {
+	case "":
+		return gosnmp.NoPriv, nil
+	case "DES":
+		return gosnmp.DES, nil
+	case "AES":
+		return gosnmp.AES, nil
+	case "AES192":
+		return gosnmp.AES192, nil
+	case "AES256":
+		return gosnmp.AES256, nil
+	}
+	return 0, fmt.Errorf("unsupported SNMP priv protocol %q", protocol)
+}
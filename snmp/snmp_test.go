@@ -0,0 +1,237 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package snmp
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/gosnmp/gosnmp"
+)
+
+// agent is a minimal in-process SNMPv2c agent answering GetNext and GetBulk
+// requests from a fixed table.
+type agent struct {
+	t     *testing.T
+	conn  net.PacketConn
+	oids  []string
+	table map[string]int
+}
+
+func newAgent(t *testing.T, table map[string]int) *agent {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &agent{t: t, conn: conn, table: table}
+	for oid := range table {
+		a.oids = append(a.oids, oid)
+	}
+	slices.SortFunc(a.oids, compareOIDs)
+	t.Cleanup(func() { conn.Close() })
+	go a.serve()
+	return a
+}
+
+func (a *agent) port() uint16 {
+	return uint16(a.conn.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func (a *agent) serve() {
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c, Logger: gosnmp.NewLogger(nil)}
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil {
+			a.t.Errorf("decoding request: %v", err)
+			return
+		}
+		resp := &gosnmp.SnmpPacket{
+			Version:   req.Version,
+			Community: req.Community,
+			PDUType:   gosnmp.GetResponse,
+			RequestID: req.RequestID,
+		}
+		repetitions := 1
+		if req.PDUType == gosnmp.GetBulkRequest {
+			repetitions = int(req.MaxRepetitions)
+		}
+		for _, v := range req.Variables {
+			oid := strings.TrimPrefix(v.Name, ".")
+			for range repetitions {
+				next, ok := a.next(oid)
+				if !ok {
+					resp.Variables = append(resp.Variables, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView})
+					break
+				}
+				resp.Variables = append(resp.Variables, gosnmp.SnmpPDU{Name: next, Type: gosnmp.Integer, Value: a.table[next]})
+				oid = next
+			}
+		}
+		out, err := resp.MarshalMsg()
+		if err != nil {
+			a.t.Errorf("encoding response: %v", err)
+			return
+		}
+		if _, err := a.conn.WriteTo(out, addr); err != nil {
+			return
+		}
+	}
+}
+
+// next returns the first OID in the table after oid.
+func (a *agent) next(oid string) (string, bool) {
+	i, found := slices.BinarySearchFunc(a.oids, oid, compareOIDs)
+	if found {
+		i++
+	}
+	if i >= len(a.oids) {
+		return "", false
+	}
+	return a.oids[i], true
+}
+
+func compareOIDs(a, b string) int {
+	return slices.Compare(oidParts(a), oidParts(b))
+}
+
+func oidParts(oid string) []int {
+	var parts []int
+	for _, s := range strings.Split(oid, ".") {
+		i, _ := strconv.Atoi(s)
+		parts = append(parts, i)
+	}
+	return parts
+}
+
+func testClient(t *testing.T, a *agent) *Client {
+	t.Helper()
+	c, err := New(slog.New(slog.DiscardHandler), "127.0.0.1", Config{
+		Port:      a.port(),
+		Timeout:   time.Second,
+		Version:   "2c",
+		Community: "public",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestFetchStatusADSL(t *testing.T) {
+	a := newAgent(t, map[string]int{
+		ifType + ".1": 6, // ethernetCsmacd
+		ifType + ".4": 238,
+
+		adslAtucCurrSnrMgn + ".4":         61,
+		adslAtucCurrAtn + ".4":            123,
+		adslAtucCurrAttainableRate + ".4": 1234000,
+		adslAturCurrSnrMgn + ".4":         75,
+		adslAturCurrAtn + ".4":            245,
+		adslAturCurrAttainableRate + ".4": 21000000,
+		adslAtucChanCurrTxRate + ".4":     18000000,
+		adslAturChanCurrTxRate + ".4":     1000000,
+		adslAtucPerfESs + ".4":            7,
+		adslAturPerfESs + ".4":            8,
+
+		// adslAtucPerfStatFastR and adslAtucPerfStatFailedFastR must not be
+		// reported as SES or UAS.
+		adslExtMibObjects + ".18.1.1.4": 111,
+		adslExtMibObjects + ".18.1.2.4": 222,
+		adslAtucPerfStatSes + ".4":      3,
+		adslAtucPerfStatUas + ".4":      4,
+		adslAturPerfStatSes + ".4":      5,
+		adslAturPerfStatUas + ".4":      6,
+	})
+
+	got, err := testClient(t, a).FetchStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := vigorv5.Status{
+		ActualRateDownstream:     18000000,
+		ActualRateUpstream:       1000000,
+		AttainableRateDownstream: 21000000,
+		AttainableRateUpstream:   1234000,
+		SNRMarginDownstream:      7.5,
+		SNRMarginUpstream:        6.1,
+		AttenuationNearEnd:       24.5,
+		AttenuationFarEnd:        12.3,
+		EsNearEnd:                8,
+		EsFarEnd:                 7,
+		SesNearEnd:               5,
+		SesFarEnd:                3,
+		UasNearEnd:               6,
+		UasFarEnd:                4,
+	}
+	if got != want {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestFetchStatusVDSL2(t *testing.T) {
+	a := newAgent(t, map[string]int{
+		ifType + ".2": 251,
+
+		xdsl2LineStatusAttainableRateDs + ".2":                     110000000,
+		xdsl2LineStatusAttainableRateUs + ".2":                     40000000,
+		xdsl2LineBandStatusSnrMargin + ".2." + xdsl2BandUpstream:   90,
+		xdsl2LineBandStatusSnrMargin + ".2." + xdsl2BandDownstream: 100,
+		xdsl2ChStatusActDataRate + ".2." + xdsl2UnitXtuc:           20000000,
+		xdsl2ChStatusActDataRate + ".2." + xdsl2UnitXtur:           100000000,
+		xdsl2ChStatusIntlvDepth + ".2." + xdsl2UnitXtur:            16,
+		// ADSL-LINE-MIB values are only used when VDSL2-LINE-MIB is missing.
+		adslAturCurrSnrMgn + ".2": 1,
+	})
+
+	got, err := testClient(t, a).FetchStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := vigorv5.Status{
+		ActualRateDownstream:      100000000,
+		ActualRateUpstream:        20000000,
+		AttainableRateDownstream:  110000000,
+		AttainableRateUpstream:    40000000,
+		InterleaveDepthDownstream: 16,
+		SNRMarginDownstream:       10,
+		SNRMarginUpstream:         9,
+	}
+	if got != want {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestFetchStatusNoDSLInterface(t *testing.T) {
+	a := newAgent(t, map[string]int{
+		ifType + ".1": 6,
+	})
+
+	_, err := testClient(t, a).FetchStatus()
+	if !errors.Is(err, ErrNoDSLInterface) {
+		t.Errorf("got error %v, want %v", err, ErrNoDSLInterface)
+	}
+}
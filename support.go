@@ -0,0 +1,204 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	"github.com/SuperQ/draytek_exporter/detect"
+	"github.com/SuperQ/draytek_exporter/drayos"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/common/version"
+)
+
+var (
+	supportCmd    = kingpin.Command("support-bundle", "Collect a redacted support bundle from the target, to attach to bug reports")
+	supportOutput = supportCmd.Flag("output", "File to write the support bundle to").Short('o').Default("draytek_support_bundle.tar.gz").String()
+)
+
+// supportReport is the summary written to report.json in the support bundle.
+type supportReport struct {
+	Version string                   `json:"version"`
+	Created time.Time                `json:"created"`
+	Target  string                   `json:"target"`
+	Detect  supportResult            `json:"detect"`
+	Driver  string                   `json:"driver"`
+	Login   supportResult            `json:"login"`
+	Results map[string]supportResult `json:"results"`
+}
+
+// supportResult is the parse result of a request, or the error it returned.
+type supportResult struct {
+	Result any    `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newSupportResult(result any, err error) supportResult {
+	if err != nil {
+		return supportResult{Error: err.Error()}
+	}
+	return supportResult{Result: result}
+}
+
+// runSupportBundle logs in to the target with the driver api, or the
+// detected one if api is auto, fetches all known pages and the pids of the custom
+// modules, and writes the redacted exchanges, parse results, errors and a
+// debug log to a tar.gz file. Errors from the target are recorded in the
+// bundle instead of being returned.
+func runSupportBundle(target string, api string, username string, password string, timeout time.Duration, modules []config.Module) error {
+	dir, err := os.MkdirTemp("", "draytek_support_bundle")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var debugLog bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&debugLog, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	report := supportReport{
+		Version: version.Info(),
+		Created: time.Now().UTC(),
+		Target:  target,
+		Results: map[string]supportResult{},
+	}
+	r, err := detect.New(logger, timeout, *drayosInsecureSkipVerify).Detect(target)
+	report.Detect = newSupportResult(r, err)
+	report.Driver = api
+	if report.Driver == "auto" {
+		report.Driver = detect.APIVigorV5
+		if err == nil {
+			report.Driver = r.API
+		}
+	}
+
+	switch report.Driver {
+	case detect.APIVigorV5:
+		err = supportVigorV5(&report, filepath.Join(dir, "exchanges"), target, username, password, modules, logger)
+	case detect.APIDrayOSREST:
+		err = supportDrayOS(&report, target, username, password, logger)
+	default:
+		report.Login = newSupportResult(nil, fmt.Errorf("the support bundle does not support the %s driver", report.Driver))
+	}
+	if err != nil {
+		return err
+	}
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "report.json"), append(reportJSON, '\n'), 0o600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "debug.log"), debugLog.Bytes(), 0o600); err != nil {
+		return err
+	}
+
+	return writeTarGz(*supportOutput, dir)
+}
+
+// supportVigorV5 records every exchange with a Vigor v5 target to dir. Only
+// the errors of the custom modules are added to the report, their responses
+// are in the redacted exchanges.
+func supportVigorV5(report *supportReport, dir string, target string, username string, password string, modules []config.Module, logger *slog.Logger) error {
+	rt, err := vigorv5.NewRecordTransport(dir, nil, *redactKeys...)
+	if err != nil {
+		return err
+	}
+	v, err := vigorv5.New(logger, target, username, password,
+		vigorv5.WithTransport(rt),
+		vigorv5.WithRedactKeys(*redactKeys...),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = v.Login()
+	report.Login = newSupportResult(nil, err)
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		if err := v.Logout(); err != nil {
+			logger.Warn("Failed to log out of DrayTek device", "err", err)
+		}
+	}()
+	report.Results["system"] = newSupportResult(v.FetchSystemInfo())
+	report.Results["dsl"] = newSupportResult(v.FetchStatus())
+	report.Results["wireless"] = newSupportResult(v.FetchWirelessStatus())
+	report.Results["lte"] = newSupportResult(v.FetchLTEStatus())
+	report.Results["voip"] = newSupportResult(v.FetchVoIPStatus())
+	report.Results["sessions"] = newSupportResult(v.FetchSessionStatus())
+	for _, m := range modules {
+		_, err := v.Query(m.PID, m.Op, m.CT)
+		report.Results["module_"+m.Name] = newSupportResult(nil, err)
+	}
+	return nil
+}
+
+// supportDrayOS adds the parse results of a DrayOS target to the report.
+// Exchanges with the REST API are not recorded.
+func supportDrayOS(report *supportReport, target string, username string, password string, logger *slog.Logger) error {
+	c, err := drayos.New(logger, *drayosScheme+"://"+target, username, password, *drayosInsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	err = c.Login()
+	report.Login = newSupportResult(nil, err)
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		if err := c.Logout(); err != nil {
+			logger.Warn("Failed to log out of DrayTek device", "err", err)
+		}
+	}()
+	report.Results["system"] = newSupportResult(c.FetchSystem())
+	report.Results["dsl"] = newSupportResult(c.FetchStatus())
+	report.Results["interfaces"] = newSupportResult(c.FetchInterfaces())
+	return nil
+}
+
+// writeTarGz writes the files in dir to a gzipped tarball at path.
+func writeTarGz(path string, dir string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	if err := tw.AddFS(os.DirFS(dir)); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,27 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// This package provides a syslog receiver for DrayTek Vigor routers.
+//
+// # Notes
+//
+// DrayTek routers send BSD style (RFC 3164) syslog messages with the tag
+// `Vigor:`. The message body does not follow a single format, the category
+// of an event is derived from well known markers in the body.
+//
+// Example:
+// * `<134>Jan  2 15:04:05 Vigor: [FILTER][Block][LAN/RT/VPN->WAN, 00:01:02 ][@S:R=13:1, 192.168.1.10:52154->1.1.1.1:443][TCP]`
+// * `<134>Jan  2 15:04:05 Vigor: ADSL_Status:[mode=VDSL2(17a) G.993.2 Annex B state=SHOWTIME]`
+// * `<134>Jan  2 15:04:05 Vigor: PPPoE ==> Protocol:LCP(c021) ConfReq Identify=0x01`
+
+package syslog
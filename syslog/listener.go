@@ -0,0 +1,154 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package syslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "draytek"
+
+	maxMessageSize = 8192
+)
+
+// Listener receives syslog messages over UDP and TCP and turns them into
+// metrics.
+type Listener struct {
+	logger *slog.Logger
+	events *ring
+
+	eventsTotal  *prometheus.CounterVec
+	invalidTotal prometheus.Counter
+}
+
+// New returns a Listener that keeps the last bufferSize events.
+func New(logger *slog.Logger, bufferSize int) *Listener {
+	return &Listener{
+		logger: logger,
+		events: newRing(bufferSize),
+		eventsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "syslog",
+				Name:      "events_total",
+				Help:      "The number of syslog events received by category and event",
+			},
+			[]string{"category", "event"},
+		),
+		invalidTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "syslog",
+				Name:      "invalid_messages_total",
+				Help:      "The number of syslog messages that could not be parsed",
+			},
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (l *Listener) Describe(ch chan<- *prometheus.Desc) {
+	l.eventsTotal.Describe(ch)
+	l.invalidTotal.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (l *Listener) Collect(ch chan<- prometheus.Metric) {
+	l.eventsTotal.Collect(ch)
+	l.invalidTotal.Collect(ch)
+}
+
+// ListenUDP receives syslog datagrams on the given address. It blocks until
+// the socket fails.
+func (l *Listener) ListenUDP(address string) error {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	l.logger.Info("Listening for syslog", "protocol", "udp", "address", conn.LocalAddr())
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		l.handle(string(buf[:n]), addr)
+	}
+}
+
+// ListenTCP receives newline delimited syslog messages on the given address.
+// It blocks until the listener fails.
+func (l *Listener) ListenTCP(address string) error {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	l.logger.Info("Listening for syslog", "protocol", "tcp", "address", ln.Addr())
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go l.serveConn(conn)
+	}
+}
+
+func (l *Listener) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, maxMessageSize), maxMessageSize)
+	for scanner.Scan() {
+		l.handle(scanner.Text(), conn.RemoteAddr())
+	}
+	if err := scanner.Err(); err != nil {
+		l.logger.Debug("Syslog connection failed", "remote", conn.RemoteAddr(), "err", err)
+	}
+}
+
+func (l *Listener) handle(line string, addr net.Addr) {
+	e, err := Parse(line, time.Now())
+	if err != nil {
+		l.logger.Debug("Unable to parse syslog message", "remote", addr, "err", err)
+		l.invalidTotal.Inc()
+		return
+	}
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		e.Source = host
+	}
+
+	l.logger.Debug("Got syslog event", "source", e.Source, "category", e.Category, "event", e.Event)
+	l.eventsTotal.WithLabelValues(e.Category, e.Event).Inc()
+	l.events.add(e)
+}
+
+// ServeHTTP serves the buffered events as JSON, oldest first.
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(l.events.list()); err != nil {
+		l.logger.Error("Unable to encode syslog events", "err", err)
+	}
+}
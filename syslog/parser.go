@@ -0,0 +1,208 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package syslog
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidMessage = errors.New("invalid syslog message")
+
+const (
+	rfc3164TimeLayout = time.Stamp
+)
+
+// Event is a single parsed syslog message.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	Host     string    `json:"host,omitempty"`
+	Severity int       `json:"severity"`
+	Category string    `json:"category"`
+	Event    string    `json:"event"`
+	Message  string    `json:"message"`
+}
+
+type rule struct {
+	category string
+	re       *regexp.Regexp
+	// event returns the event name from the regexp submatches.
+	event func(m []string) string
+}
+
+func fixed(event string) func([]string) string {
+	return func([]string) string { return event }
+}
+
+// oneOf returns the normalized submatch i if it is one of events, otherwise
+// "other". Anyone on the network can send syslog messages, so only known event
+// names become label values.
+func oneOf(i int, events ...string) func([]string) string {
+	return func(m []string) string {
+		return allowed(normalize(m[i]), events)
+	}
+}
+
+func allowed(event string, events []string) string {
+	if slices.Contains(events, event) {
+		return event
+	}
+	return "other"
+}
+
+var (
+	dslStates    = []string{"showtime", "training", "handshake", "ready", "idle", "down"}
+	pppProtocols = []string{"lcp", "pap", "chap", "ipcp", "ipv6cp"}
+	pppEvents    = []string{
+		"confreq", "confack", "confnak", "confrej", "termreq", "termack",
+		"echoreq", "echorep", "success", "failure", "challenge", "response",
+	}
+	filterActions = []string{"block", "pass"}
+	dosAttacks    = []string{
+		"syn_flood", "udp_flood", "icmp_flood", "port_scan", "ip_option",
+		"land_attack", "smurf_attack", "trace_route", "syn_fragment",
+		"fraggle_attack", "tcp_flag_scan", "tear_drop_attack",
+		"ping_of_death_attack", "icmp_fragment", "unknown_protocol",
+	}
+	wanEvents = []string{"up", "down", "online", "offline"}
+)
+
+// rules are evaluated in order, the first match wins.
+var rules = []rule{
+	{
+		category: "dsl",
+		re:       regexp.MustCompile(`[AV]DSL_Status:\[.*state=(\w+)`),
+		event:    oneOf(1, dslStates...),
+	},
+	{
+		category: "ppp",
+		re:       regexp.MustCompile(`PPP(?:oE|oA)? (?:==>|<==) Protocol:(\w+)\([0-9a-fA-F]+\) (\w+)`),
+		event: func(m []string) string {
+			protocol := allowed(normalize(m[1]), pppProtocols)
+			event := allowed(normalize(m[2]), pppEvents)
+			if protocol == "other" || event == "other" {
+				return "other"
+			}
+			return protocol + "_" + event
+		},
+	},
+	{
+		category: "firewall",
+		re:       regexp.MustCompile(`^\[FILTER\]\[(\w+)\]`),
+		event:    oneOf(1, filterActions...),
+	},
+	{
+		category: "dos",
+		re:       regexp.MustCompile(`^\[DOS\]\[([^\]]+)\]`),
+		event:    oneOf(1, dosAttacks...),
+	},
+	{
+		category: "admin",
+		re:       regexp.MustCompile(`(?i)login.*(fail|invalid)`),
+		event:    fixed("login_failure"),
+	},
+	{
+		category: "admin",
+		re:       regexp.MustCompile(`(?i)login.*success`),
+		event:    fixed("login_success"),
+	},
+	{
+		category: "admin",
+		re:       regexp.MustCompile(`(?i)logout`),
+		event:    fixed("logout"),
+	},
+	{
+		category: "wan",
+		re:       regexp.MustCompile(`(?i)^WAN\d+ .*\b(up|down|online|offline)\b`),
+		event:    oneOf(1, wanEvents...),
+	},
+}
+
+// Parse parses a DrayTek syslog line. Messages that do not match a known
+// format are returned with the category and event "other".
+func Parse(line string, now time.Time) (Event, error) {
+	line = strings.TrimRight(line, "\r\n\x00")
+	if line == "" {
+		return Event{}, ErrInvalidMessage
+	}
+
+	e := Event{
+		Time:     now,
+		Severity: -1,
+		Category: "other",
+		Event:    "other",
+	}
+
+	if strings.HasPrefix(line, "<") {
+		end := strings.IndexByte(line, '>')
+		if end < 0 {
+			return Event{}, ErrInvalidMessage
+		}
+		pri, err := strconv.Atoi(line[1:end])
+		if err != nil {
+			return Event{}, ErrInvalidMessage
+		}
+		e.Severity = pri % 8
+		line = line[end+1:]
+	}
+
+	if len(line) >= len(rfc3164TimeLayout) {
+		if t, err := time.ParseInLocation(rfc3164TimeLayout, line[:len(rfc3164TimeLayout)], time.Local); err == nil {
+			e.Time = t.AddDate(now.Year(), 0, 0)
+			line = strings.TrimLeft(line[len(rfc3164TimeLayout):], " ")
+		}
+	}
+
+	// The tag is terminated by a colon, optionally preceded by the hostname.
+	if tag, msg, ok := strings.Cut(line, ": "); ok && !strings.ContainsAny(tag, "[]") {
+		fields := strings.Fields(tag)
+		if len(fields) == 2 {
+			e.Host = fields[0]
+		}
+		line = msg
+	}
+	e.Message = line
+
+	for _, r := range rules {
+		m := r.re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		e.Category = r.category
+		e.Event = r.event(m)
+		break
+	}
+
+	return e, nil
+}
+
+// normalize converts an event name into a lower case, underscore separated
+// string suitable for a label value.
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '_'
+		}
+	}, strings.TrimSpace(s))
+}
@@ -0,0 +1,50 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package syslog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.Local)
+	for _, tc := range []struct {
+		line     string
+		category string
+		event    string
+	}{
+		{"<134>Jan  2 15:04:05 Vigor: [FILTER][Block][LAN/RT/VPN->WAN, 00:01:02 ][@S:R=13:1, 192.168.1.10:52154->1.1.1.1:443][TCP]", "firewall", "block"},
+		{"<134>Jan  2 15:04:05 Vigor: ADSL_Status:[mode=VDSL2(17a) G.993.2 Annex B state=SHOWTIME]", "dsl", "showtime"},
+		{"<134>Jan  2 15:04:05 Vigor: PPPoE ==> Protocol:LCP(c021) ConfReq Identify=0x01", "ppp", "lcp_confreq"},
+		{"<134>Jan  2 15:04:05 Vigor: [DOS][SYN flood][192.0.2.1:1234->192.168.1.1:80]", "dos", "syn_flood"},
+		{"<134>Jan  2 15:04:05 Vigor: WAN1 PPPoE is up", "wan", "up"},
+		{"<134>Jan  2 15:04:05 Vigor: Admin login successful", "admin", "login_success"},
+		{"<134>Jan  2 15:04:05 Vigor: something else entirely", "other", "other"},
+		// Unknown event names from the sender must not become label values.
+		{"<134>Jan  2 15:04:05 Vigor: [FILTER][Random123][x]", "firewall", "other"},
+		{"<134>Jan  2 15:04:05 Vigor: ADSL_Status:[state=Attacker_Chosen_1]", "dsl", "other"},
+		{"<134>Jan  2 15:04:05 Vigor: PPPoE ==> Protocol:XYZ(c021) ConfReq", "ppp", "other"},
+		{"<134>Jan  2 15:04:05 Vigor: [DOS][made up attack 42]", "dos", "other"},
+	} {
+		e, err := Parse(tc.line, now)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.line, err)
+			continue
+		}
+		if e.Category != tc.category || e.Event != tc.event {
+			t.Errorf("%q: got %s/%s, want %s/%s", tc.line, e.Category, e.Event, tc.category, tc.event)
+		}
+	}
+}
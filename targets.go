@@ -0,0 +1,392 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	"github.com/SuperQ/draytek_exporter/detect"
+	"github.com/SuperQ/draytek_exporter/drayos"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/jpillora/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	drayosScheme             = kingpin.Flag("drayos.scheme", "URL scheme used to reach the DrayOS REST API").Default("https").Enum("http", "https")
+	drayosInsecureSkipVerify = kingpin.Flag("drayos.insecure-skip-verify", "Skip TLS certificate verification of the DrayOS REST API").Default("false").Bool()
+)
+
+var errLegacyHTML = errors.New("models with the legacy HTML web UI are not supported")
+
+// detectRetryMin and detectRetryMax bound the backoff between detection
+// attempts of targets whose API could not be detected.
+var (
+	detectRetryMin = 10 * time.Second
+	detectRetryMax = 10 * time.Minute
+)
+
+// target is a device from the config file with its own session.
+type target struct {
+	config config.Target
+	// driver is the API used for the target. If detection failed, it is
+	// vigor_v5 and guessed is set, detection is retried in the background.
+	driver    string
+	guessed   bool
+	login     *loginManager
+	collector *DrayTekCollector
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mtx         sync.RWMutex
+	credentials credentialsFunc
+}
+
+// newTarget creates the driver for t and starts logging in to it in the
+// background. The credentials are looked up again on every login.
+func newTarget(ctx context.Context, t config.Target, modules []config.Module, detector *detect.Detector, logger *slog.Logger) (*target, error) {
+	logger = logger.With("target", t.Name)
+	credentials := targetCredentials(t)
+	if credentials == nil {
+		return nil, fmt.Errorf("%w: target %s has no password_file or credential_helper", errMissingPassword, t.Name)
+	}
+	nt := &target{
+		config:      t,
+		credentials: credentials,
+	}
+
+	var (
+		fetcher StatusFetcher
+		device  Loginer
+	)
+	driver := t.Driver
+	if driver == config.DriverAuto {
+		r, err := detector.Detect(t.Address)
+		if err != nil {
+			driver = detect.APIVigorV5
+			nt.guessed = true
+			logger.Warn("Unable to detect device API, assuming "+driver+" until it is detected", "err", err)
+		} else {
+			driver = r.API
+		}
+	}
+	nt.driver = driver
+	switch driver {
+	case detect.APIVigorV5:
+		location, err := voipLocation()
+		if err != nil {
+			return nil, err
+		}
+		v, err := vigorv5.New(logger, t.Address, t.Username, "",
+			vigorv5.WithUnsafeDebug(*unsafeDebug),
+			vigorv5.WithRedactKeys(*redactKeys...),
+			vigorv5.WithLocation(location),
+			vigorv5.WithCredentials(nt.getCredentials),
+		)
+		if err != nil {
+			return nil, err
+		}
+		fetcher, device = v, v
+	case detect.APIDrayOSREST:
+		c, err := drayos.New(logger, *drayosScheme+"://"+t.Address, t.Username, "", *drayosInsecureSkipVerify,
+			drayos.WithCredentials(nt.getCredentials),
+		)
+		if err != nil {
+			return nil, err
+		}
+		fetcher, device = c, c
+	case detect.APILegacyHTML:
+		return nil, errLegacyHTML
+	default:
+		return nil, fmt.Errorf("unsupported device API %q", driver)
+	}
+
+	nt.login = newLoginManager(device, logger, *loginPerScrape)
+	collector, err := NewDrayTekCollector(fetcher, nt.login, modules, logger)
+	if err != nil {
+		return nil, err
+	}
+	nt.collector = collector
+
+	nt.ctx, nt.cancel = context.WithCancel(ctx)
+	nt.login.start(nt.ctx)
+	return nt, nil
+}
+
+// getCredentials returns the credentials for the next login.
+func (t *target) getCredentials(refresh bool) (string, string, error) {
+	t.mtx.RLock()
+	credentials := t.credentials
+	t.mtx.RUnlock()
+	return credentials(refresh)
+}
+
+// sameDevice reports whether c refers to the same device as the target, so
+// that its session can be kept.
+func (t *target) sameDevice(c config.Target) bool {
+	return t.config.Address == c.Address && t.config.Driver == c.Driver
+}
+
+// stop ends the background login and the session on the device.
+func (t *target) stop() {
+	t.cancel()
+	t.login.logout()
+}
+
+// targetManager serves the metrics of the targets in the config file on the
+// probe endpoint, selected by the target URL parameter.
+type targetManager struct {
+	logger *slog.Logger
+	// detector caches the API of targets with the auto driver, so that they
+	// are only probed once.
+	detector *detect.Detector
+
+	// updateMtx is held from prepare until commit or abort, and while a
+	// target is replaced after its API was detected.
+	updateMtx sync.Mutex
+
+	mtx     sync.RWMutex
+	targets map[string]*target
+	modules []config.Module
+}
+
+func newTargetManager(logger *slog.Logger, detector *detect.Detector) *targetManager {
+	return &targetManager{
+		logger:   logger,
+		detector: detector,
+		targets:  map[string]*target{},
+	}
+}
+
+// update replaces the current targets with targets. Targets that still refer
+// to the same device keep their session, changed credentials are used on
+// their next login. If any target can not be created, the current targets are
+// left unchanged.
+func (m *targetManager) update(ctx context.Context, targets []config.Target, modules []config.Module) error {
+	u, err := m.prepare(ctx, targets, modules)
+	if err != nil {
+		return err
+	}
+	u.commit()
+	return nil
+}
+
+// targetUpdate is a prepared change of the targets, applied by commit or
+// discarded by abort.
+type targetUpdate struct {
+	m       *targetManager
+	ctx     context.Context
+	modules []config.Module
+	targets map[string]*target
+	created []*target
+	updates []credentialsUpdate
+	// collectors are the recreated collectors of the kept targets.
+	collectors map[*target]map[string]Collector
+}
+
+type credentialsUpdate struct {
+	t           *target
+	config      config.Target
+	credentials credentialsFunc
+}
+
+// prepare creates the new targets and the collectors of the kept targets for
+// modules, without changing the current targets. The update must be applied
+// with commit or discarded with abort.
+func (m *targetManager) prepare(ctx context.Context, targets []config.Target, modules []config.Module) (*targetUpdate, error) {
+	m.updateMtx.Lock()
+	m.mtx.RLock()
+	current := m.targets
+	m.mtx.RUnlock()
+
+	u := &targetUpdate{
+		m:          m,
+		ctx:        ctx,
+		modules:    modules,
+		targets:    make(map[string]*target, len(targets)),
+		collectors: map[*target]map[string]Collector{},
+	}
+	for _, t := range targets {
+		old, ok := current[t.Name]
+		var err error
+		switch {
+		case ok && reflect.DeepEqual(old.config, t):
+			u.targets[t.Name] = old
+		case ok && old.sameDevice(t):
+			credentials := targetCredentials(t)
+			if credentials == nil {
+				err = fmt.Errorf("%w: target %s has no password_file or credential_helper", errMissingPassword, t.Name)
+				break
+			}
+			u.updates = append(u.updates, credentialsUpdate{old, t, credentials})
+			u.targets[t.Name] = old
+		default:
+			var nt *target
+			nt, err = newTarget(ctx, t, modules, m.detector, m.logger)
+			if err != nil {
+				err = fmt.Errorf("unable to create target %s: %w", t.Name, err)
+				break
+			}
+			u.created = append(u.created, nt)
+			u.targets[t.Name] = nt
+		}
+		if err == nil && ok && u.targets[t.Name] == old {
+			var collectors map[string]Collector
+			collectors, err = old.collector.prepareReload(modules)
+			if err != nil {
+				err = fmt.Errorf("unable to reload collectors of target %s: %w", t.Name, err)
+			}
+			u.collectors[old] = collectors
+		}
+		if err != nil {
+			u.abort()
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// commit replaces the current targets with the prepared ones.
+func (u *targetUpdate) commit() {
+	m := u.m
+	defer m.updateMtx.Unlock()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, c := range u.updates {
+		m.logger.Info("Updating credentials of target", "target", c.config.Name)
+		c.t.mtx.Lock()
+		c.t.config = c.config
+		c.t.credentials = c.credentials
+		c.t.mtx.Unlock()
+	}
+	for t, collectors := range u.collectors {
+		t.collector.setCollectors(collectors)
+	}
+	for name, t := range m.targets {
+		if u.targets[name] == t {
+			continue
+		}
+		m.logger.Info("Removing target", "target", name)
+		t.stop()
+	}
+	for _, t := range u.created {
+		m.logger.Info("Adding target", "target", t.config.Name)
+		if t.guessed {
+			go m.redetect(u.ctx, t)
+		}
+	}
+	m.targets = u.targets
+	m.modules = u.modules
+}
+
+// abort stops the targets created by prepare.
+func (u *targetUpdate) abort() {
+	defer u.m.updateMtx.Unlock()
+	for _, nt := range u.created {
+		nt.stop()
+	}
+}
+
+// redetect retries detecting the API of t with backoff until it succeeds or
+// t is stopped. If the API differs from the guessed driver, t is replaced by
+// a new target using the detected API.
+func (m *targetManager) redetect(ctx context.Context, t *target) {
+	b := &backoff.Backoff{
+		Min:    detectRetryMin,
+		Max:    detectRetryMax,
+		Factor: 2,
+		Jitter: true,
+	}
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-time.After(b.Duration()):
+		}
+		t.mtx.RLock()
+		c := t.config
+		t.mtx.RUnlock()
+		r, err := m.detector.Detect(c.Address)
+		if err != nil {
+			m.logger.Debug("Unable to detect device API", "target", c.Name, "err", err)
+			continue
+		}
+		if r.API == t.driver {
+			return
+		}
+
+		m.updateMtx.Lock()
+		defer m.updateMtx.Unlock()
+		m.mtx.RLock()
+		current, modules := m.targets[c.Name], m.modules
+		m.mtx.RUnlock()
+		if current != t {
+			return
+		}
+		nt, err := newTarget(ctx, c, modules, m.detector, m.logger)
+		if err != nil {
+			m.logger.Error("Unable to replace target with detected API", "target", c.Name, "api", r.API, "err", err)
+			return
+		}
+		m.logger.Info("Replacing target with detected API", "target", c.Name, "api", r.API)
+		m.mtx.Lock()
+		m.targets[c.Name] = nt
+		m.mtx.Unlock()
+		t.stop()
+		return
+	}
+}
+
+// stop ends the sessions on all targets.
+func (m *targetManager) stop() {
+	m.updateMtx.Lock()
+	defer m.updateMtx.Unlock()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, t := range m.targets {
+		t.stop()
+	}
+	m.targets = map[string]*target{}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *targetManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("target")
+	if name == "" {
+		http.Error(w, "'target' parameter must be specified", http.StatusBadRequest)
+		return
+	}
+	m.mtx.RLock()
+	t, ok := m.targets[name]
+	m.mtx.RUnlock()
+	if !ok {
+		http.Error(w, "unknown target "+name, http.StatusNotFound)
+		return
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(t.collector, t.login)
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
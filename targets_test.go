@@ -0,0 +1,98 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	"github.com/SuperQ/draytek_exporter/detect"
+)
+
+func TestTargetRedetect(t *testing.T) {
+	defer func(scheme string, minRetry, maxRetry time.Duration) {
+		*drayosScheme = scheme
+		detectRetryMin, detectRetryMax = minRetry, maxRetry
+	}(*drayosScheme, detectRetryMin, detectRetryMax)
+	*drayosScheme = "http"
+	detectRetryMin, detectRetryMax = time.Millisecond, 10*time.Millisecond
+
+	// The device does not answer the detection probes until it is ready,
+	// then it serves the DrayOS REST API.
+	var ready atomic.Bool
+	router := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ready.Load() && r.URL.Path == "/api/" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer router.Close()
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := newTargetManager(logger, detect.New(logger, time.Second, false))
+	defer m.stop()
+	err := m.update(ctx, []config.Target{{
+		Name:         "office",
+		Address:      strings.TrimPrefix(router.URL, "http://"),
+		Driver:       config.DriverAuto,
+		Username:     "monitor",
+		PasswordFile: passwordFile,
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := func() *target {
+		m.mtx.RLock()
+		defer m.mtx.RUnlock()
+		return m.targets["office"]
+	}
+	guessed := current()
+	if guessed.driver != detect.APIVigorV5 || !guessed.guessed {
+		t.Fatalf("got driver %q guessed %t, want guessed %s", guessed.driver, guessed.guessed, detect.APIVigorV5)
+	}
+
+	ready.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for current() == guessed {
+		if time.Now().After(deadline) {
+			t.Fatal("target was not replaced after its API was detected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := current(); got.driver != detect.APIDrayOSREST || got.guessed {
+		t.Errorf("got driver %q guessed %t, want detected %s", got.driver, got.guessed, detect.APIDrayOSREST)
+	}
+	if guessed.ctx.Err() == nil {
+		t.Error("guessed target was not stopped")
+	}
+}
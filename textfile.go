@@ -0,0 +1,67 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	textfilePath     = kingpin.Flag("output.textfile", "Write the metrics of the target to this file for the node_exporter textfile collector instead of serving them over HTTP").Default("").String()
+	textfileInterval = kingpin.Flag("output.textfile.interval", "Interval to rewrite the textfile at, 0 to write it once and exit").Default("0s").Duration()
+)
+
+// runTextfile writes the metrics of the device to the textfile once, or every
+// textfile interval until ctx is done. The file is replaced atomically, so
+// node_exporter never reads a partially written file.
+func runTextfile(ctx context.Context, fetcher StatusFetcher, login *loginManager, logger *slog.Logger) error {
+	collector, err := NewDrayTekCollector(fetcher, login, getCustomModules(), logger)
+	if err != nil {
+		return err
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	if login != nil {
+		registry.MustRegister(login)
+	}
+
+	// Log in before the first write, so that it does not report the login as
+	// pending.
+	login.loginOnce()
+	if *textfileInterval <= 0 {
+		return prometheus.WriteToTextfile(*textfilePath, registry)
+	}
+
+	// Keep retrying in the background if the first login failed.
+	login.start(ctx)
+	ticker := time.NewTicker(*textfileInterval)
+	defer ticker.Stop()
+	for {
+		if err := prometheus.WriteToTextfile(*textfilePath, registry); err != nil {
+			logger.Error("Unable to write textfile", "file", *textfilePath, "err", err)
+		} else {
+			logger.Debug("Wrote textfile", "file", *textfilePath)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
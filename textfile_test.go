@@ -0,0 +1,69 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+)
+
+// staticFetcher returns an empty DSL status.
+type staticFetcher struct{}
+
+func (staticFetcher) FetchStatus() (vigorv5.Status, error) {
+	return vigorv5.Status{}, nil
+}
+
+// writeTextfileOnce runs the textfile interval mode until the first write and
+// returns the file.
+func writeTextfileOnce(t *testing.T, login *loginManager) string {
+	t.Helper()
+	defer func(path string, interval time.Duration) {
+		*textfilePath, *textfileInterval = path, interval
+	}(*textfilePath, *textfileInterval)
+	*textfilePath = filepath.Join(t.TempDir(), "draytek.prom")
+	*textfileInterval = time.Minute
+
+	// The context is done, so only the first write happens.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := runTextfile(ctx, staticFetcher{}, login, slog.New(slog.DiscardHandler)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(*textfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestTextfileIntervalWithoutLogin(t *testing.T) {
+	if got := writeTextfileOnce(t, nil); !strings.Contains(got, "draytek_up 1") {
+		t.Errorf("got textfile %q, want draytek_up 1", got)
+	}
+}
+
+func TestTextfileIntervalLogsInFirst(t *testing.T) {
+	login := newLoginManager(fakeLoginer{}, slog.New(slog.DiscardHandler), false)
+	if got := writeTextfileOnce(t, login); !strings.Contains(got, "draytek_up 1") {
+		t.Errorf("got textfile %q, want draytek_up 1", got)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// batchWindow is how long a request waits for other requests to be combined
+// with it. Collectors run concurrently, so their requests arrive together.
+const batchWindow = 10 * time.Millisecond
+
+type batchRequest struct {
+	form   vigorForm
+	result chan batchResult
+}
+
+type batchResult struct {
+	respJSON string
+	err      error
+}
+
+// fetch posts p, combining its tables with those of any other requests made
+// within batchWindow into a single webproc.cgi request. The response is split
+// so that each request only sees its own tables.
+func (v *Vigor) fetch(p vigorForm) (string, error) {
+	req := &batchRequest{
+		form:   p,
+		result: make(chan batchResult, 1),
+	}
+
+	v.batchMtx.Lock()
+	v.batch = append(v.batch, req)
+	if len(v.batch) == 1 {
+		time.AfterFunc(batchWindow, v.flushBatch)
+	}
+	v.batchMtx.Unlock()
+
+	result := <-req.result
+	return result.respJSON, result.err
+}
+
+func (v *Vigor) flushBatch() {
+	v.batchMtx.Lock()
+	reqs := v.batch
+	v.batch = nil
+	v.batchMtx.Unlock()
+
+	ops := map[string][]*batchRequest{}
+	for _, req := range reqs {
+		ops[req.form.op] = append(ops[req.form.op], req)
+	}
+	for _, reqs := range ops {
+		v.postBatch(reqs)
+	}
+}
+
+// postBatch sends reqs, which must share the same op, as one request. If the
+// combined request is rejected, each request is retried on its own, as are
+// requests whose tables are missing from the combined response.
+func (v *Vigor) postBatch(reqs []*batchRequest) {
+	if len(reqs) == 1 {
+		respJSON, err := v.postWithLogin(reqs[0].form)
+		reqs[0].result <- batchResult{respJSON, err}
+		return
+	}
+
+	pids := make([]string, 0, len(reqs))
+	tables := make([]string, 0, len(reqs))
+	seen := map[string]bool{}
+	for _, req := range reqs {
+		pids = append(pids, req.form.pid)
+		for _, table := range gjson.Get(req.form.ct, "ct").Array() {
+			if seen[table.Raw] {
+				continue
+			}
+			seen[table.Raw] = true
+			tables = append(tables, table.Raw)
+		}
+	}
+	v.logger.Debug("Batching pids", "pids", strings.Join(pids, ","))
+
+	form := vigorForm{
+		pid: reqs[0].form.pid,
+		op:  reqs[0].form.op,
+		ct:  `{"param":[],"ct":[` + strings.Join(tables, ",") + `]}`,
+	}
+	respJSON, err := v.post(form)
+	if errors.Is(err, errUnauthorized) {
+		v.logger.Debug("Batched request unauthorized, attempting login", "err", err)
+		v.mtx.Lock()
+		v.relogins++
+		v.mtx.Unlock()
+		if err = v.Login(); err == nil {
+			respJSON, err = v.post(form)
+		}
+	}
+
+	// Retrying is pointless when the router is unreachable or refuses the
+	// login.
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, ErrLoginFailed) || errors.Is(err, ErrCredentialsFailed) {
+		for _, req := range reqs {
+			req.result <- batchResult{"", fmt.Errorf("%w: %w", ErrRequestFailed, err)}
+		}
+		return
+	}
+
+	results := make([]string, len(reqs))
+	if err == nil {
+		results = splitBatch(respJSON, reqs)
+	} else {
+		v.logger.Debug("Batched request rejected, retrying pids individually", "err", err)
+	}
+	for i, req := range reqs {
+		if results[i] != "" {
+			req.result <- batchResult{results[i], nil}
+			continue
+		}
+		if err == nil {
+			v.logger.Debug("Tables missing from batched response, retrying pid individually", "pid", req.form.pid)
+		}
+		respJSON, err := v.postWithLogin(req.form)
+		req.result <- batchResult{respJSON, err}
+	}
+}
+
+// splitBatch splits the tables of a batched response back into one response
+// per request, in the order of reqs. The response of a request is empty if
+// none of its tables were found.
+func splitBatch(respJSON string, reqs []*batchRequest) []string {
+	owners := map[string][]int{}
+	for i, req := range reqs {
+		for _, table := range gjson.Get(req.form.ct, "ct").Array() {
+			table.ForEach(func(key, _ gjson.Result) bool {
+				owners[key.String()] = append(owners[key.String()], i)
+				return true
+			})
+		}
+	}
+
+	parts := make([][]string, len(reqs))
+	for _, table := range gjson.Get(respJSON, "ct").Array() {
+		table.ForEach(func(key, _ gjson.Result) bool {
+			for _, i := range owners[key.String()] {
+				parts[i] = append(parts[i], table.Raw)
+			}
+			return false
+		})
+	}
+
+	rid := gjson.Get(respJSON, "rid").Raw
+	results := make([]string, len(reqs))
+	for i := range reqs {
+		if len(parts[i]) == 0 {
+			continue
+		}
+		results[i] = `{"rid":` + rid + `,"ct":[` + strings.Join(parts[i], ",") + `]}`
+	}
+	return results
+}
@@ -0,0 +1,192 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// batchTransport records the requests passed on to the replay transport.
+// If reject is set, requests for more than one table are answered with an
+// error rid.
+type batchTransport struct {
+	next   http.RoundTripper
+	reject bool
+
+	mtx      sync.Mutex
+	requests []vigorForm
+}
+
+func (t *batchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	form, _ := url.ParseQuery(string(body))
+	ct, _ := decodeVigorString(form.Get("ct"))
+
+	t.mtx.Lock()
+	t.requests = append(t.requests, vigorForm{pid: form.Get("pid"), op: form.Get("op"), ct: ct})
+	t.mtx.Unlock()
+
+	if t.reject && len(gjson.Get(ct, "ct").Array()) > 1 {
+		return replayResponse(req, http.StatusOK, `{"rid":"0003","ct":[]}`), nil
+	}
+	req.Body = io.NopCloser(strings.NewReader(string(body)))
+	return t.next.RoundTrip(req)
+}
+
+func batchVigor(t *testing.T, reject bool) (*Vigor, *batchTransport) {
+	t.Helper()
+	rt, err := NewReplayTransport("testdata/batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bt := &batchTransport{next: rt, reject: reject}
+	v, err := New(slog.New(slog.DiscardHandler), "192.0.2.1", "monitor", "secret", WithTransport(bt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Login(); err != nil {
+		t.Fatal(err)
+	}
+	bt.requests = nil
+	return v, bt
+}
+
+func newBatchRequest(pid, table string) *batchRequest {
+	return &batchRequest{
+		form: vigorForm{
+			pid: pid,
+			op:  "501",
+			ct:  `{"param":[],"ct":[{"` + table + `":[]}]}`,
+		},
+		result: make(chan batchResult, 1),
+	}
+}
+
+// tableNames returns the table names of a request or response body.
+func tableNames(body string) []string {
+	var names []string
+	for _, table := range gjson.Get(body, "ct").Array() {
+		table.ForEach(func(key, _ gjson.Result) bool {
+			names = append(names, key.String())
+			return false
+		})
+	}
+	return names
+}
+
+func checkBatchResults(t *testing.T, reqs []*batchRequest, want [][]string) {
+	t.Helper()
+	for i, req := range reqs {
+		result := <-req.result
+		if result.err != nil {
+			t.Errorf("%s: unexpected error: %v", req.form.pid, result.err)
+			continue
+		}
+		if rid := gjson.Get(result.respJSON, "rid").String(); rid != "0000" {
+			t.Errorf("%s: got rid %q, want 0000", req.form.pid, rid)
+		}
+		if got := strings.Join(tableNames(result.respJSON), ","); got != strings.Join(want[i], ",") {
+			t.Errorf("%s: got tables %q, want %q", req.form.pid, got, strings.Join(want[i], ","))
+		}
+	}
+}
+
+func checkBatchRequests(t *testing.T, bt *batchTransport, want []string) {
+	t.Helper()
+	var got []string
+	for _, req := range bt.requests {
+		got = append(got, req.pid+":"+strings.Join(tableNames(req.ct), ","))
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got requests %q, want %q", got, want)
+	}
+}
+
+func TestPostBatch(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		reject bool
+		reqs   []*batchRequest
+		// results holds the tables returned to each request.
+		results [][]string
+		// requests holds the pid and tables sent to the router.
+		requests []string
+	}{
+		{
+			name: "split",
+			reqs: []*batchRequest{
+				newBatchRequest("0STATUS_WAN", "WAN"),
+				newBatchRequest("0STATUS_LAN", "LAN"),
+			},
+			results:  [][]string{{"WAN"}, {"LAN"}},
+			requests: []string{"0STATUS_WAN:WAN,LAN"},
+		},
+		{
+			name: "dedup",
+			reqs: []*batchRequest{
+				newBatchRequest("0STATUS_WAN", "WAN"),
+				newBatchRequest("0STATUS_LAN", "LAN"),
+				newBatchRequest("0STATUS_WAN", "WAN"),
+			},
+			results:  [][]string{{"WAN"}, {"LAN"}, {"WAN"}},
+			requests: []string{"0STATUS_WAN:WAN,LAN"},
+		},
+		{
+			name: "partial",
+			reqs: []*batchRequest{
+				newBatchRequest("0STATUS_WAN", "WAN"),
+				newBatchRequest("0STATUS_VPN", "VPN"),
+			},
+			results:  [][]string{{"WAN"}, nil},
+			requests: []string{"0STATUS_WAN:WAN,VPN", "0STATUS_VPN:VPN"},
+		},
+		{
+			name:   "fallback",
+			reject: true,
+			reqs: []*batchRequest{
+				newBatchRequest("0STATUS_WAN", "WAN"),
+				newBatchRequest("0STATUS_LAN", "LAN"),
+			},
+			results:  [][]string{{"WAN"}, {"LAN"}},
+			requests: []string{"0STATUS_WAN:WAN,LAN", "0STATUS_WAN:WAN", "0STATUS_LAN:LAN"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, bt := batchVigor(t, tc.reject)
+
+			v.postBatch(tc.reqs)
+
+			checkBatchResults(t, tc.reqs, tc.results)
+			checkBatchRequests(t, bt, tc.requests)
+			if v.Logins() != 1 {
+				t.Errorf("got %d logins, want 1", v.Logins())
+			}
+			if v.Relogins() != 0 {
+				t.Errorf("got %d relogins, want 0", v.Relogins())
+			}
+		})
+	}
+}
@@ -20,14 +20,18 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/tidwall/gjson"
 )
 
 var ErrLoginFailed = errors.New("login failed")
+var ErrCredentialsFailed = errors.New("unable to get credentials")
+var ErrLogoutFailed = errors.New("logout failed")
 
 const (
 	loginJSONTemplate = `{"param":[],"ct":[{"Name":"%s","Password":"%s","locales":"en"}]}`
+	logoutJSON        = `{"param":[],"ct":[]}`
 )
 
 func (v *Vigor) Login() error {
@@ -38,13 +42,36 @@ func (v *Vigor) Login() error {
 		v.logger.Error("Unable to generate new CSRF token", "err", err)
 		return err
 	}
+	v.mtx.Lock()
 	v.csrf = hex.EncodeToString(token)
+	v.mtx.Unlock()
 
-	v.logger.Debug("Attempting login", "username", v.username)
+	username, password := v.username, v.password
+	if v.credentials != nil {
+		v.mtx.Lock()
+		refresh := v.rejected
+		v.mtx.Unlock()
+
+		username, password, err = v.credentials(refresh)
+		if err != nil {
+			v.logger.Debug("Unable to get credentials", "err", err)
+			return fmt.Errorf("%w: %w", ErrCredentialsFailed, err)
+		}
+	}
+
+	err = v.login(username, password)
+	v.mtx.Lock()
+	v.rejected = errors.Is(err, ErrLoginFailed)
+	v.mtx.Unlock()
+	return err
+}
+
+func (v *Vigor) login(username string, password string) error {
+	v.logger.Debug("Attempting login", "username", username)
 	post := vigorForm{
 		pid: "event",
 		op:  "552",
-		ct:  encodeLogin(v.username, v.password),
+		ct:  encodeLogin(username, password),
 	}
 	resp, err := v.postForm(post)
 	if err != nil {
@@ -75,14 +102,71 @@ func (v *Vigor) Login() error {
 	}
 
 	for _, cookie := range v.jar.Cookies(v.cgiURL) {
-		v.logger.Debug("Got Cookie", "name", cookie.Name, "value", cookie.Value)
+		v.logger.Debug("Got Cookie", "name", cookie.Name, "cookie", cookie.Value)
 	}
 
+	v.mtx.Lock()
+	v.loginTime = time.Now()
+	v.logins++
+	// Newer firmware reports the admin idle timeout in seconds.
+	if timeout := gjson.Get(respJSON, "ct.0.Timeout").Int(); timeout > 0 {
+		v.idleTimeout = time.Duration(timeout) * time.Second
+	}
+	v.mtx.Unlock()
+
 	v.logger.Debug("Login OK")
 
 	return nil
 }
 
+// Logout ends the session on the router, so it does not count towards the
+// admin session limit until it times out.
+func (v *Vigor) Logout() error {
+	v.mtx.Lock()
+	loggedIn := !v.loginTime.IsZero()
+	v.loginTime = time.Time{}
+	v.mtx.Unlock()
+	if !loggedIn {
+		return nil
+	}
+
+	v.logger.Debug("Attempting logout", "username", v.username)
+	post := vigorForm{
+		pid: "event",
+		op:  "553",
+		ct:  logoutJSON,
+	}
+	resp, err := v.postForm(post)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		v.logger.Debug("Server returned non-ok http status", "status", resp.Status)
+		return ErrLogoutFailed
+	}
+
+	v.logger.Debug("Logout OK")
+
+	return nil
+}
+
+// SessionStart returns the time of the last successful login, or the zero
+// time if there is no session.
+func (v *Vigor) SessionStart() time.Time {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return v.loginTime
+}
+
+// Logins returns the number of successful logins.
+func (v *Vigor) Logins() uint64 {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return v.logins
+}
+
 func encodeLogin(username string, password string) string {
 	h := sha512.New()
 	h.Write([]byte(password))
@@ -90,3 +174,38 @@ func encodeLogin(username string, password string) string {
 
 	return fmt.Sprintf(loginJSONTemplate, username, encodedPassword)
 }
+
+// Relogins returns the number of requests that were rejected by the router,
+// for example because the session expired, and needed a new login.
+func (v *Vigor) Relogins() uint64 {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return v.relogins
+}
+
+// IdleTimeout returns the idle timeout of the session reported by the router,
+// or 0 if it is unknown.
+func (v *Vigor) IdleTimeout() time.Duration {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return v.idleTimeout
+}
+
+// LastRequest returns the time of the last request to the router.
+func (v *Vigor) LastRequest() time.Time {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return v.lastRequest
+}
+
+// Keepalive refreshes the session by fetching the DSL general status, logging
+// in again if the session already expired.
+func (v *Vigor) Keepalive() error {
+	v.logger.Debug("Sending session keepalive")
+	_, err := v.postWithLogin(vigorForm{
+		pid: "0MONITORING_DSL_GENERAL",
+		op:  "501",
+		ct:  dslStatusGeneral,
+	})
+	return err
+}
@@ -0,0 +1,100 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"github.com/tidwall/gjson"
+)
+
+const (
+	lteStatusGeneral = `{"param":[],"ct":[{"0MONITORING_LTE_GENERAL":[]},{"1MON_LTE_SIGNAL_TABLE":[]},{"1MON_LTE_USAGE_TABLE":[]}]}`
+)
+
+type LTEStatus struct {
+	Status   string
+	SIMState string
+	Operator string
+	Mode     string
+	Band     string
+	CellID   string
+
+	RSRP float64
+	RSRQ float64
+	SINR float64
+	RSSI float64
+
+	TxBytes int
+	RxBytes int
+}
+
+func (v *Vigor) FetchLTEStatus() (LTEStatus, error) {
+	post := vigorForm{
+		pid: "0MONITORING_LTE_GENERAL",
+		op:  "501",
+		ct:  lteStatusGeneral,
+	}
+
+	resp, err := v.fetch(post)
+	if err != nil {
+		v.logger.Debug("Got error from post", "err", err)
+		return LTEStatus{}, err
+	}
+
+	return v.parseLTEStatusGeneralJSON(resp)
+}
+
+func (v *Vigor) parseLTEStatusGeneralJSON(respJSON string) (LTEStatus, error) {
+	value := gjson.Get(respJSON, "ct.0.0MONITORING_LTE_GENERAL.#(Name==\"Setting\")")
+	if !value.Exists() {
+		v.logger.Debug("Unable to get settings", "response_json", respJSON)
+		return LTEStatus{}, ErrParseFailed
+	}
+
+	v.logger.Debug("Parsed LTE Status General json", "json", value.String())
+
+	status := LTEStatus{
+		Status:   value.Get("Status").String(),
+		SIMState: value.Get("SIM_Status").String(),
+		Operator: value.Get("Operator").String(),
+		Mode:     value.Get("Mode").String(),
+		Band:     value.Get("Band").String(),
+		CellID:   value.Get("Cell_ID").String(),
+	}
+
+	signalTable := value.Get("Signal_Table").Array()
+	for _, v := range signalTable {
+		switch v.Get("Name").String() {
+		case "RSRP":
+			status.RSRP = parsedBm(v.Get("Value").String())
+		case "RSRQ":
+			status.RSRQ = parsedB(v.Get("Value").String())
+		case "SINR":
+			status.SINR = parsedB(v.Get("Value").String())
+		case "RSSI":
+			status.RSSI = parsedBm(v.Get("Value").String())
+		}
+	}
+
+	usageTable := value.Get("Usage_Table").Array()
+	for _, v := range usageTable {
+		switch v.Get("Name").String() {
+		case "Tx":
+			status.TxBytes = parseBytes(v.Get("Value").String())
+		case "Rx":
+			status.RxBytes = parseBytes(v.Get("Value").String())
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,103 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"testing"
+)
+
+// lteJSON returns an LTE status response with the given signal and usage
+// table rows.
+func lteJSON(signal string, usage string) string {
+	return `{"rid":"0000","ct":[{"0MONITORING_LTE_GENERAL":[{"Name":"Setting","Status":"Connected","SIM_Status":"Ready",` +
+		`"Operator":"Example","Mode":"LTE","Band":"B20","Cell_ID":"1A2B3C",` +
+		`"Signal_Table":[` + signal + `],"Usage_Table":[` + usage + `]}]}]}`
+}
+
+func TestParseLTEStatus(t *testing.T) {
+	info := LTEStatus{
+		Status:   "Connected",
+		SIMState: "Ready",
+		Operator: "Example",
+		Mode:     "LTE",
+		Band:     "B20",
+		CellID:   "1A2B3C",
+	}
+	for _, tc := range []struct {
+		name   string
+		signal string
+		usage  string
+		want   LTEStatus
+	}{
+		{
+			name: "connected",
+			signal: `{"Name":"RSRP","Value":"-95 dBm"},{"Name":"RSRQ","Value":"-11.5 dB"},` +
+				`{"Name":"SINR","Value":"12 dB"},{"Name":"RSSI","Value":"-67 dBm"}`,
+			usage: `{"Name":"Tx","Value":"1.5 GB"},{"Name":"Rx","Value":"512 MB"}`,
+			want: LTEStatus{
+				RSRP: -95, RSRQ: -11.5, SINR: 12, RSSI: -67,
+				TxBytes: 1.5 * (1 << 30), RxBytes: 512 << 20,
+			},
+		},
+		{
+			name:   "negative SINR",
+			signal: `{"Name":"SINR","Value":"-3.5 dB"}`,
+			want:   LTEStatus{SINR: -3.5},
+		},
+		{
+			name: "not available",
+			signal: `{"Name":"RSRP","Value":"N/A"},{"Name":"RSRQ","Value":"N/A"},` +
+				`{"Name":"SINR","Value":"N/A"},{"Name":"RSSI","Value":"N/A"}`,
+			usage: `{"Name":"Tx","Value":"N/A"},{"Name":"Rx","Value":"N/A"}`,
+		},
+		{
+			name:   "empty",
+			signal: `{"Name":"RSRP","Value":""},{"Name":"RSSI","Value":""}`,
+			usage:  `{"Name":"Tx","Value":""},{"Name":"Rx","Value":""}`,
+		},
+		{
+			name:   "wrong units",
+			signal: `{"Name":"RSRP","Value":"-95 dB"},{"Name":"RSSI","Value":"-67 mW"}`,
+			usage:  `{"Name":"Tx","Value":"12 packets"}`,
+		},
+		{
+			name:  "negative usage",
+			usage: `{"Name":"Tx","Value":"-1 MB"},{"Name":"Rx","Value":"2 KB"}`,
+			want:  LTEStatus{RxBytes: 2 << 10},
+		},
+		{
+			name: "no tables",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := testVigor().parseLTEStatusGeneralJSON(lteJSON(tc.signal, tc.usage))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want
+			want.Status, want.SIMState, want.Operator = info.Status, info.SIMState, info.Operator
+			want.Mode, want.Band, want.CellID = info.Mode, info.Band, info.CellID
+			if got != want {
+				t.Errorf("got  %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseLTEStatusMissingSettings(t *testing.T) {
+	_, err := testVigor().parseLTEStatusGeneralJSON(`{"rid":"0000","ct":[{"0MONITORING_LTE_GENERAL":[]}]}`)
+	if err != ErrParseFailed {
+		t.Errorf("got error %v, want %v", err, ErrParseFailed)
+	}
+}
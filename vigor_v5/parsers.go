@@ -0,0 +1,51 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Parsers maps the names of the value parsers used in custom modules to
+// functions that turn a value shown by the router into a float64. Values that
+// can not be parsed are returned as 0, like in the built-in collectors.
+var Parsers = map[string]func(string) float64{
+	"float":   parseFloat,
+	"count":   func(s string) float64 { return float64(parseCount(s)) },
+	"option":  func(s string) float64 { return boolToFloat64(parseOption(s)) },
+	"seconds": func(s string) float64 { return float64(parseSeconds(s)) },
+	"kbps":    func(s string) float64 { return float64(parseKbps(s)) },
+	"mbps":    func(s string) float64 { return float64(parseMbps(s)) },
+	"mhz":     func(s string) float64 { return float64(parseMHz(s)) },
+	"db":      parsedB,
+	"dbm":     parsedBm,
+	"bytes":   func(s string) float64 { return float64(parseBytes(s)) },
+	"percent": parsePercent,
+}
+
+func parseFloat(s string) float64 {
+	x, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return x
+}
+
+func boolToFloat64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Query posts an arbitrary pid, op and ct to the router, logging in first if
+// there is no session, and returns the decoded JSON response as is. Responses
+// with a rid other than "0000" are returned too, as they are needed when
+// exploring pages that are not supported yet.
+func (v *Vigor) Query(pid string, op string, ct string) (string, error) {
+	if v.SessionStart().IsZero() {
+		if err := v.Login(); err != nil {
+			return "", err
+		}
+	}
+	resp, err := v.postForm(vigorForm{
+		pid: pid,
+		op:  op,
+		ct:  ct,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return "", fmt.Errorf("%w: %s", ErrRequestFailed, resp.Status)
+	}
+	return decodeVigorJSON(resp)
+}
+
+// Fetch posts a pid, op and ct to the router like the built-in requests,
+// logging in again when the session expired, and returns the decoded JSON
+// response. Concurrent fetches are batched. It is meant for custom modules.
+func (v *Vigor) Fetch(pid string, op string, ct string) (string, error) {
+	return v.fetch(vigorForm{
+		pid: pid,
+		op:  op,
+		ct:  ct,
+	})
+}
+
+// Encode encodes JSON into the prefix-padded base64 form used by webproc.cgi.
+func Encode(j string) string {
+	return encodeVigorJSON(j)
+}
+
+// Decode decodes a prefix-padded base64 payload captured from webproc.cgi.
+func Decode(s string) (string, error) {
+	return decodeVigorString(strings.TrimSpace(s))
+}
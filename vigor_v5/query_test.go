@@ -0,0 +1,61 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func replayVigor(t *testing.T, dir string) *Vigor {
+	t.Helper()
+	rt, err := NewReplayTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := New(slog.New(slog.DiscardHandler), "192.0.2.1", "monitor", "secret", WithTransport(rt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestQueryReturnsErrorRID(t *testing.T) {
+	v := replayVigor(t, "testdata/query")
+
+	resp, err := v.Query("0SYSTEM_UNKNOWN", "501", `{"param":[],"ct":[]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rid := gjson.Get(resp, "rid").String(); rid != "0003" {
+		t.Errorf("got rid %q, want 0003 in %s", rid, resp)
+	}
+	if v.Logins() != 1 {
+		t.Errorf("got %d logins, want 1", v.Logins())
+	}
+	if v.Relogins() != 0 {
+		t.Errorf("got %d relogins, want 0", v.Relogins())
+	}
+}
+
+func TestQueryNotFound(t *testing.T) {
+	v := replayVigor(t, "testdata/query")
+
+	if _, err := v.Query("0SYSTEM_MISSING", "501", `{"param":[],"ct":[]}`); !errors.Is(err, ErrRequestFailed) {
+		t.Errorf("got error %v, want %v", err, ErrRequestFailed)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// exchange is a recorded webproc.cgi request and response, with the ct
+// payloads decoded. Cookies and the CSRF token are not recorded.
+type exchange struct {
+	PID      string          `json:"pid"`
+	Op       string          `json:"op"`
+	Status   int             `json:"status"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// recordTransport writes every exchange with the router to a directory.
+type recordTransport struct {
+	dir      string
+	next     http.RoundTripper
+	redactor *redactor
+
+	mtx sync.Mutex
+	seq int
+}
+
+// NewRecordTransport returns a http.RoundTripper that sends requests with next
+// and writes each decoded webproc.cgi exchange to a file in dir, with
+// passwords, hashes, tokens and the values of redactKeys redacted. The files
+// can be served back with NewReplayTransport. If next is nil,
+// http.DefaultTransport is used.
+func NewRecordTransport(dir string, next http.RoundTripper, redactKeys ...string) (http.RoundTripper, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recordTransport{dir: dir, next: next, redactor: newRedactor(redactKeys...)}, nil
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var form url.Values
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		form, _ = url.ParseQuery(string(body))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	ct, _ := decodeVigorString(form.Get("ct"))
+	respJSON, _ := decodeVigorString(string(body))
+	t.write(exchange{
+		PID:      form.Get("pid"),
+		Op:       form.Get("op"),
+		Status:   resp.StatusCode,
+		Request:  rawJSON(t.redactor.json(ct)),
+		Response: rawJSON(t.redactor.json(respJSON)),
+	})
+
+	return resp, nil
+}
+
+func (t *recordTransport) write(e exchange) {
+	t.mtx.Lock()
+	t.seq++
+	name := fmt.Sprintf("%04d_%s_%s.json", t.seq, unsafeFileChars.ReplaceAllString(e.PID, "_"), unsafeFileChars.ReplaceAllString(e.Op, "_"))
+	t.mtx.Unlock()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(e); err != nil {
+		return
+	}
+	// Recording is best effort, it must not break scraping.
+	_ = os.WriteFile(filepath.Join(t.dir, name), buf.Bytes(), 0o600)
+}
+
+// rawJSON returns j as a json.RawMessage, or as a JSON string if it is not
+// valid JSON.
+func rawJSON(j string) json.RawMessage {
+	if json.Valid([]byte(j)) {
+		return json.RawMessage(j)
+	}
+	b, _ := json.Marshal(j)
+	return b
+}
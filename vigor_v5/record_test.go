@@ -0,0 +1,84 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordRedactKeys(t *testing.T) {
+	replay, err := NewReplayTransport("testdata/system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	record, err := NewRecordTransport(dir, replay, "Serial_Number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := New(slog.New(slog.DiscardHandler), "192.0.2.1", "monitor", "secret", WithTransport(record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Login(); err != nil {
+		t.Fatal(err)
+	}
+	want, err := v.FetchSystemInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d recorded exchanges, want 2", len(files))
+	}
+	hash := sha512.Sum512([]byte("secret"))
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{"2765ABCDEF123456", hex.EncodeToString(hash[:])} {
+			if strings.Contains(string(b), secret) {
+				t.Errorf("%s contains %q", filepath.Base(file), secret)
+			}
+		}
+	}
+
+	// The redacted recording still replays.
+	replay, err = NewReplayTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = New(slog.New(slog.DiscardHandler), "192.0.2.1", "monitor", "", WithTransport(replay))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := v.FetchSystemInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %+v from the recording, want %+v", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+)
+
+const redacted = "<redacted>"
+
+// sensitiveKeys are JSON keys and log attributes whose values are redacted,
+// compared case insensitively.
+var sensitiveKeys = []string{
+	"password",
+	"passwd",
+	"psk",
+	"token",
+	"_token",
+	"cookie",
+	"session",
+	"sid",
+}
+
+// redactor masks secrets in JSON payloads and log attributes.
+type redactor struct {
+	keys map[string]bool
+}
+
+// newRedactor returns a redactor for the default sensitive keys plus extra.
+func newRedactor(extra ...string) *redactor {
+	r := &redactor{keys: map[string]bool{}}
+	for _, key := range append(sensitiveKeys, extra...) {
+		r.keys[strings.ToLower(key)] = true
+	}
+	return r
+}
+
+// json replaces the values of sensitive keys in j. Invalid JSON is returned
+// as is.
+func (r *redactor) json(j string) string {
+	d := json.NewDecoder(strings.NewReader(j))
+	d.UseNumber()
+	var value any
+	if err := d.Decode(&value); err != nil {
+		return j
+	}
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(r.value(value)); err != nil {
+		return j
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func (r *redactor) value(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for k, v := range value {
+			if r.keys[strings.ToLower(k)] {
+				value[k] = redacted
+				continue
+			}
+			value[k] = r.value(v)
+		}
+	case []any:
+		for i, v := range value {
+			value[i] = r.value(v)
+		}
+	}
+	return value
+}
+
+// attr masks a sensitive log attribute. Attributes with a key ending in
+// "json" have the sensitive keys of their JSON value masked.
+func (r *redactor) attr(a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case r.keys[key]:
+		return slog.String(a.Key, redacted)
+	case a.Value.Kind() == slog.KindGroup:
+		attrs := a.Value.Group()
+		redactedAttrs := make([]any, 0, len(attrs))
+		for _, attr := range attrs {
+			redactedAttrs = append(redactedAttrs, r.attr(attr))
+		}
+		return slog.Group(a.Key, redactedAttrs...)
+	case strings.HasSuffix(key, "json"):
+		return slog.String(a.Key, r.json(a.Value.String()))
+	}
+	return a
+}
+
+// redactHandler is a slog.Handler that masks secrets before passing records
+// on to the next handler.
+type redactHandler struct {
+	next slog.Handler
+	r    *redactor
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	out := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.r.attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redactedAttrs = append(redactedAttrs, h.r.attr(a))
+	}
+	return &redactHandler{next: h.next.WithAttrs(redactedAttrs), r: h.r}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), r: h.r}
+}
@@ -0,0 +1,168 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		extra []string
+		in    string
+		want  string
+	}{
+		{
+			name: "password",
+			in:   `{"Name":"monitor","Password":"s3cret"}`,
+			want: `{"Name":"monitor","Password":"<redacted>"}`,
+		},
+		{
+			name: "ct payload",
+			in:   `{"param":[],"ct":[{"Name":"monitor","Password":"s3cret","locales":"en"}]}`,
+			want: `{"ct":[{"Name":"monitor","Password":"<redacted>","locales":"en"}],"param":[]}`,
+		},
+		{
+			name: "nested keys",
+			in:   `{"rid":"0000","ct":[{"WLAN":[{"SSID":"Office","Security":{"PSK":"wifi-secret","Mode":"WPA2"}}]}],"_token":"abc"}`,
+			want: `{"_token":"<redacted>","ct":[{"WLAN":[{"SSID":"Office","Security":{"Mode":"WPA2","PSK":"<redacted>"}}]}],"rid":"0000"}`,
+		},
+		{
+			name: "object value",
+			in:   `{"session":{"id":"1234","user":"monitor"}}`,
+			want: `{"session":"<redacted>"}`,
+		},
+		{
+			name:  "extra keys",
+			extra: []string{"PIN", "SharedSecret"},
+			in:    `{"ct":[{"Pin":"1234","sharedsecret":"vpn-secret","Status":"Up"}]}`,
+			want:  `{"ct":[{"Pin":"<redacted>","Status":"Up","sharedsecret":"<redacted>"}]}`,
+		},
+		{
+			name: "numbers",
+			in:   `{"Uptime":12345678901234567890,"Rate":1.50}`,
+			want: `{"Rate":1.50,"Uptime":12345678901234567890}`,
+		},
+		{
+			name: "invalid json",
+			in:   `{"Password":"s3cret"`,
+			want: `{"Password":"s3cret"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := newRedactor(tc.extra...).json(tc.in); got != tc.want {
+				t.Errorf("got  %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// redactLogger returns a logger writing to buf through a redactHandler.
+func redactLogger(buf *bytes.Buffer, extra ...string) *slog.Logger {
+	next := slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(&redactHandler{next: next, r: newRedactor(extra...)})
+}
+
+func TestRedactHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		log  func(logger *slog.Logger)
+		want string
+	}{
+		{
+			name: "attr",
+			log: func(logger *slog.Logger) {
+				logger.Debug("Login", "username", "monitor", "Password", "s3cret")
+			},
+			want: `msg=Login username=monitor Password=<redacted>`,
+		},
+		{
+			name: "json attr",
+			log: func(logger *slog.Logger) {
+				logger.Debug("Unable to get settings", "response_json", `{"ct":[{"Password":"s3cret"}]}`)
+			},
+			want: `response_json="{\"ct\":[{\"Password\":\"<redacted>\"}]}"`,
+		},
+		{
+			name: "group attr",
+			log: func(logger *slog.Logger) {
+				logger.Debug("Post", slog.Group("request", "pid", "event", "_token", "csrf-token"))
+			},
+			want: `request.pid=event request._token=<redacted>`,
+		},
+		{
+			name: "with attrs",
+			log: func(logger *slog.Logger) {
+				logger.With("cookie", "SESSION=abc", "target", "office").Debug("Post")
+			},
+			want: `msg=Post cookie=<redacted> target=office`,
+		},
+		{
+			name: "with group",
+			log: func(logger *slog.Logger) {
+				logger.WithGroup("wireless").With("psk", "wifi-secret").Debug("Parsed", "token", "abc")
+			},
+			want: `msg=Parsed wireless.psk=<redacted> wireless.token=<redacted>`,
+		},
+		{
+			name: "extra key",
+			log: func(logger *slog.Logger) {
+				logger.Debug("Parsed", "pin", "1234", "json", `{"PIN":"1234"}`)
+			},
+			want: `pin=<redacted> json="{\"PIN\":\"<redacted>\"}"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.log(redactLogger(&buf, "pin"))
+			got := buf.String()
+			if !strings.Contains(got, tc.want) {
+				t.Errorf("got  %s\nwant %s", got, tc.want)
+			}
+			for _, secret := range []string{"s3cret", "csrf-token", "SESSION=abc", "wifi-secret", "1234", "=abc"} {
+				if strings.Contains(got, secret) {
+					t.Errorf("secret %q not redacted in %s", secret, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRedactsLogger(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		opts  []Option
+		wants string
+	}{
+		{name: "default", wants: "Password=<redacted> pin=<redacted>", opts: []Option{WithRedactKeys("pin")}},
+		{name: "unsafe debug", wants: "Password=s3cret pin=1234", opts: []Option{WithRedactKeys("pin"), WithUnsafeDebug(true)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			v, err := New(logger, "192.0.2.1", "monitor", "s3cret", tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v.logger.Debug("Login", "Password", v.password, "pin", "1234")
+			if !strings.Contains(buf.String(), tc.wants) {
+				t.Errorf("got  %s\nwant %s", buf.String(), tc.wants)
+			}
+		})
+	}
+}
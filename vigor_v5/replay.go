@@ -0,0 +1,146 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+var ErrNoRecording = errors.New("no recorded exchanges found")
+
+// replayTransport answers requests from exchanges recorded by
+// NewRecordTransport, without talking to a router.
+type replayTransport struct {
+	// responses holds the last response per pid and op.
+	responses map[string]exchange
+	// tables holds the last recorded response table per op and table name.
+	// Requests are answered table by table, so replay does not depend on
+	// how requests were batched while recording.
+	tables map[string]string
+}
+
+// NewReplayTransport returns a http.RoundTripper that serves the exchanges
+// recorded in dir. Use it with WithTransport to run the client against a
+// capture.
+func NewReplayTransport(dir string) (http.RoundTripper, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoRecording, dir)
+	}
+
+	t := &replayTransport{
+		responses: map[string]exchange{},
+		tables:    map[string]string{},
+	}
+	// Glob returns the files sorted, which is the order they were recorded.
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var e exchange
+		if err := json.Unmarshal(b, &e); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", file, err)
+		}
+		t.responses[e.PID+"/"+e.Op] = e
+		if e.Status != http.StatusOK {
+			continue
+		}
+		for _, table := range gjson.GetBytes(e.Response, "ct").Array() {
+			table.ForEach(func(key, _ gjson.Result) bool {
+				t.tables[e.Op+"/"+key.String()] = table.Raw
+				return true
+			})
+		}
+	}
+	return t, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var form url.Values
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		form, _ = url.ParseQuery(string(body))
+	}
+	pid, op := form.Get("pid"), form.Get("op")
+	ct, _ := decodeVigorString(form.Get("ct"))
+
+	if respJSON, ok := t.fromTables(op, ct); ok {
+		return replayResponse(req, http.StatusOK, respJSON), nil
+	}
+	if e, ok := t.responses[pid+"/"+op]; ok {
+		return replayResponse(req, e.Status, string(e.Response)), nil
+	}
+	return replayResponse(req, http.StatusNotFound, ""), nil
+}
+
+// fromTables builds a response from the recorded tables, if all tables
+// requested in ct were recorded.
+func (t *replayTransport) fromTables(op string, ct string) (string, bool) {
+	var tables []string
+	found := true
+	for _, table := range gjson.Get(ct, "ct").Array() {
+		table.ForEach(func(key, _ gjson.Result) bool {
+			raw, ok := t.tables[op+"/"+key.String()]
+			if !ok {
+				found = false
+				return false
+			}
+			tables = append(tables, raw)
+			return false
+		})
+	}
+	if !found || len(tables) == 0 {
+		return "", false
+	}
+	return `{"rid":"0000","ct":[` + strings.Join(tables, ",") + `]}`, true
+}
+
+func replayResponse(req *http.Request, status int, respJSON string) *http.Response {
+	header := http.Header{}
+	// The client only checks that a session cookie is set on login.
+	header.Set("Set-Cookie", "SESSION=replay; Path=/")
+	body := ""
+	if respJSON != "" {
+		body = encodeVigorJSON(respJSON)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	sessionStatusGeneral = `{"param":[],"ct":[{"0DIAGNOSTICS_SESSION_GENERAL":[]},{"1DIAG_SESSION_PROTOCOL_TABLE":[]},{"1DIAG_DOS_TABLE":[]},{"1DIAG_FILTER_TABLE":[]}]}`
+)
+
+type SessionStatus struct {
+	Sessions    int
+	MaxSessions int
+
+	// ProtocolSessions maps a protocol name (tcp, udp, icmp, ...) to the
+	// number of active sessions.
+	ProtocolSessions map[string]int
+	// DoSDefense maps a DoS defence type to the number of times it was
+	// triggered.
+	DoSDefense map[string]int
+	// Blocked maps a filter (firewall, content) to the number of blocked
+	// packets or requests.
+	Blocked map[string]int
+}
+
+func (v *Vigor) FetchSessionStatus() (SessionStatus, error) {
+	post := vigorForm{
+		pid: "0DIAGNOSTICS_SESSION_GENERAL",
+		op:  "501",
+		ct:  sessionStatusGeneral,
+	}
+
+	resp, err := v.fetch(post)
+	if err != nil {
+		v.logger.Debug("Got error from post", "err", err)
+		return SessionStatus{}, err
+	}
+
+	return v.parseSessionStatusGeneralJSON(resp)
+}
+
+func (v *Vigor) parseSessionStatusGeneralJSON(respJSON string) (SessionStatus, error) {
+	value := gjson.Get(respJSON, "ct.0.0DIAGNOSTICS_SESSION_GENERAL.#(Name==\"Setting\")")
+	if !value.Exists() {
+		v.logger.Debug("Unable to get settings", "response_json", respJSON)
+		return SessionStatus{}, ErrParseFailed
+	}
+
+	v.logger.Debug("Parsed Session Status General json", "json", value.String())
+
+	status := SessionStatus{
+		Sessions:         parseCount(value.Get("Current_Sessions").String()),
+		MaxSessions:      parseCount(value.Get("Max_Sessions").String()),
+		ProtocolSessions: map[string]int{},
+		DoSDefense:       map[string]int{},
+		Blocked:          map[string]int{},
+	}
+
+	for _, v := range value.Get("Protocol_Table").Array() {
+		protocol := strings.ToLower(v.Get("Name").String())
+		status.ProtocolSessions[protocol] = parseCount(v.Get("Sessions").String())
+	}
+
+	for _, v := range value.Get("DoS_Table").Array() {
+		status.DoSDefense[v.Get("Name").String()] = parseCount(v.Get("Count").String())
+	}
+
+	for _, v := range value.Get("Filter_Table").Array() {
+		switch v.Get("Name").String() {
+		case "Firewall":
+			status.Blocked["firewall"] = parseCount(v.Get("Blocked").String())
+		case "Content Filter":
+			status.Blocked["content"] = parseCount(v.Get("Blocked").String())
+		}
+	}
+
+	return status, nil
+}
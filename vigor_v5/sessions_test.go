@@ -0,0 +1,53 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestFetchSessionStatus(t *testing.T) {
+	rt, err := NewReplayTransport("../testdata/vigor_v5/sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := New(slog.New(slog.DiscardHandler), "192.0.2.1", "monitor", "secret", WithTransport(rt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := v.FetchSessionStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SessionStatus{
+		Sessions:         1234,
+		MaxSessions:      60000,
+		ProtocolSessions: map[string]int{"tcp": 1000, "udp": 230, "icmp": 4},
+		DoSDefense:       map[string]int{"SYN_Flood": 17, "Port_Scan": 0},
+		Blocked:          map[string]int{"firewall": 5678, "content": 90},
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("got  %+v\nwant %+v", status, want)
+	}
+}
+
+func TestParseSessionStatusMissingSettings(t *testing.T) {
+	_, err := testVigor().parseSessionStatusGeneralJSON(`{"rid":"0000","ct":[{"0DIAGNOSTICS_SESSION_GENERAL":[]}]}`)
+	if err != ErrParseFailed {
+		t.Errorf("got error %v, want %v", err, ErrParseFailed)
+	}
+}
@@ -81,7 +81,7 @@ func (v *Vigor) FetchStatus() (Status, error) {
 		ct:  dslStatusGeneral,
 	}
 
-	resp, err := v.postWithLogin(post)
+	resp, err := v.fetch(post)
 	if err != nil {
 		v.logger.Debug("Got error from post", "err", err)
 		return Status{}, err
@@ -226,3 +226,50 @@ func parsedB(s string) float64 {
 	}
 	return x
 }
+
+func parsedBm(s string) float64 {
+	return parseFloatUnit(s, "dBm")
+}
+
+// parseFloatUnit parses a "<value> <unit>" string, returning 0 if the unit
+// does not match.
+func parseFloatUnit(s string, unit string) float64 {
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return 0
+	}
+	if !strings.EqualFold(parts[1], unit) {
+		return 0
+	}
+	x, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0
+	}
+	return x
+}
+
+var byteUnits = map[string]float64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// parseBytes parses a data volume such as "12.5 MB" into bytes. Negative
+// volumes are invalid and returned as 0.
+func parseBytes(s string) int {
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return 0
+	}
+	multiplier, ok := byteUnits[strings.ToUpper(parts[1])]
+	if !ok {
+		return 0
+	}
+	x, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || x < 0 {
+		return 0
+	}
+	return int(x * multiplier)
+}
@@ -0,0 +1,86 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"testing"
+)
+
+func TestParsedBm(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want float64
+	}{
+		{"-95 dBm", -95},
+		{"-61.5 dBm", -61.5},
+		{"3 dBm", 3},
+		{"-95 DBM", -95},
+		{"-95 dB", 0},
+		{"-95", 0},
+		{"N/A", 0},
+		{"N/A dBm", 0},
+		{"", 0},
+	} {
+		if got := parsedBm(tc.in); got != tc.want {
+			t.Errorf("parsedBm(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseFloatUnit(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		unit string
+		want float64
+	}{
+		{"12.5 dB", "dB", 12.5},
+		{"-3.2 dB", "dB", -3.2},
+		{" 7 db ", "dB", 7},
+		{"7 dBm", "dB", 0},
+		{"7", "dB", 0},
+		{"7 dB extra", "dB", 0},
+		{"N/A dB", "dB", 0},
+		{"N/A", "dB", 0},
+		{"", "dB", 0},
+	} {
+		if got := parseFloatUnit(tc.in, tc.unit); got != tc.want {
+			t.Errorf("parseFloatUnit(%q, %q) = %v, want %v", tc.in, tc.unit, got, tc.want)
+		}
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int
+	}{
+		{"512 B", 512},
+		{"1 KB", 1 << 10},
+		{"12.5 MB", 12.5 * (1 << 20)},
+		{"2 GB", 2 << 30},
+		{"1.5 TB", 1.5 * (1 << 40)},
+		{"3 mb", 3 << 20},
+		{"0 B", 0},
+		{"-1 MB", 0},
+		{"5 PB", 0},
+		{"512", 0},
+		{"N/A", 0},
+		{"N/A MB", 0},
+		{"", 0},
+	} {
+		if got := parseBytes(tc.in); got != tc.want {
+			t.Errorf("parseBytes(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"github.com/tidwall/gjson"
+)
+
+const (
+	systemStatusGeneral = `{"param":[],"ct":[{"0MONITORING_SYSTEM_GENERAL":[]}]}`
+)
+
+type SystemInfo struct {
+	Model     string
+	Firmware  string
+	BuildDate string
+}
+
+func (v *Vigor) FetchSystemInfo() (SystemInfo, error) {
+	post := vigorForm{
+		pid: "0MONITORING_SYSTEM_GENERAL",
+		op:  "501",
+		ct:  systemStatusGeneral,
+	}
+
+	resp, err := v.fetch(post)
+	if err != nil {
+		v.logger.Debug("Got error from post", "err", err)
+		return SystemInfo{}, err
+	}
+
+	return v.parseSystemStatusGeneralJSON(resp)
+}
+
+func (v *Vigor) parseSystemStatusGeneralJSON(respJSON string) (SystemInfo, error) {
+	value := gjson.Get(respJSON, "ct.0.0MONITORING_SYSTEM_GENERAL.#(Name==\"Setting\")")
+	if !value.Exists() {
+		v.logger.Debug("Unable to get settings", "response_json", respJSON)
+		return SystemInfo{}, ErrParseFailed
+	}
+
+	v.logger.Debug("Parsed System Status General json", "json", value.String())
+
+	return SystemInfo{
+		Model:     value.Get("Model_Name").String(),
+		Firmware:  value.Get("Firmware_Version").String(),
+		BuildDate: value.Get("Build_Date").String(),
+	}, nil
+}
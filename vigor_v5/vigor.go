@@ -24,6 +24,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tidwall/gjson"
@@ -32,17 +33,51 @@ import (
 var ErrJSONDecodeFailed = errors.New("json decode failed")
 var ErrRequestFailed = errors.New("failed to request with login")
 
+var (
+	// errUnauthorized is returned by post when the router answers with an
+	// error status or a body that is not an encoded response, as it does for
+	// an expired session.
+	errUnauthorized = errors.New("request unauthorized")
+	// errRejected is returned by post when the router answers with a rid
+	// other than "0000".
+	errRejected = errors.New("request rejected")
+)
+
+const requestTimeout = 10 * time.Second
+
+// retryDelay is multiplied by the attempt number to wait before retrying a
+// failed request.
+var retryDelay = time.Second
+
 type Vigor struct {
 	jar    *cookiejar.Jar
 	client *http.Client
 	cgiURL *url.URL
 	csrf   string
 
-	host     string
-	username string
-	password string
+	host        string
+	username    string
+	password    string
+	credentials CredentialsFunc
+
+	logger      *slog.Logger
+	unsafeDebug bool
+	redactKeys  []string
+	// location is the time zone of the timestamps reported by the router.
+	location *time.Location
+
+	mtx         sync.Mutex
+	loginTime   time.Time
+	logins      uint64
+	relogins    uint64
+	idleTimeout time.Duration
+	lastRequest time.Time
+	// rejected is set when the last login failed, to refresh the
+	// credentials on the next login.
+	rejected bool
 
-	logger *slog.Logger
+	batchMtx sync.Mutex
+	batch    []*batchRequest
 }
 
 type vigorForm struct {
@@ -51,7 +86,56 @@ type vigorForm struct {
 	ct  string
 }
 
-func New(logger *slog.Logger, host string, username string, password string) (*Vigor, error) {
+// Option configures a Vigor client.
+type Option func(*Vigor)
+
+// WithUnsafeDebug disables the redaction of cookies, tokens, password hashes
+// and other secrets in log messages.
+func WithUnsafeDebug(unsafe bool) Option {
+	return func(v *Vigor) {
+		v.unsafeDebug = unsafe
+	}
+}
+
+// WithRedactKeys adds JSON keys whose values are redacted in log messages, in
+// addition to the built-in list of password and token keys.
+func WithRedactKeys(keys ...string) Option {
+	return func(v *Vigor) {
+		v.redactKeys = append(v.redactKeys, keys...)
+	}
+}
+
+// WithLocation sets the time zone of the timestamps reported by the router,
+// which have no zone information. The default is UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(v *Vigor) {
+		v.location = loc
+	}
+}
+
+// CredentialsFunc returns the username and password to log in with. refresh
+// is true after the router rejected the previous login, so that cached
+// credentials can be fetched again.
+type CredentialsFunc func(refresh bool) (username string, password string, err error)
+
+// WithCredentials sets a function that is called on every login to get the
+// credentials, instead of using the username and password passed to New. This
+// allows rotated passwords to take effect without restarting.
+func WithCredentials(f CredentialsFunc) Option {
+	return func(v *Vigor) {
+		v.credentials = f
+	}
+}
+
+// WithTransport sets the http.RoundTripper used to talk to the router, for
+// example to record or replay exchanges.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(v *Vigor) {
+		v.client.Transport = rt
+	}
+}
+
+func New(logger *slog.Logger, host string, username string, password string, opts ...Option) (*Vigor, error) {
 	var err error
 
 	v := Vigor{
@@ -59,6 +143,7 @@ func New(logger *slog.Logger, host string, username string, password string) (*V
 		username: username,
 		password: password,
 		logger:   logger,
+		location: time.UTC,
 	}
 	v.jar, err = cookiejar.New(nil)
 	if err != nil {
@@ -71,55 +156,103 @@ func New(logger *slog.Logger, host string, username string, password string) (*V
 	}
 
 	v.client = &http.Client{
-		Jar: v.jar,
+		Jar:     v.jar,
+		Timeout: requestTimeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
 	}
+	for _, opt := range opts {
+		opt(&v)
+	}
+	if !v.unsafeDebug {
+		v.logger = slog.New(&redactHandler{next: logger.Handler(), r: newRedactor(v.redactKeys...)})
+	}
 
 	return &v, nil
 }
 
 func (v *Vigor) postForm(p vigorForm) (*http.Response, error) {
+	v.mtx.Lock()
+	csrf := v.csrf
+	v.lastRequest = time.Now()
+	v.mtx.Unlock()
+
 	urlValues := url.Values{
 		"pid":    {p.pid},
 		"op":     {p.op},
 		"ct":     {encodeVigorJSON(p.ct)},
-		"_token": {v.csrf},
+		"_token": {csrf},
 	}
 
 	v.logger.Debug("Posting pid", "pid", p.pid)
 
 	for _, cookie := range v.client.Jar.Cookies(v.cgiURL) {
-		v.logger.Debug("Post Cookie", "name", cookie.Name, "value", cookie.Value)
+		v.logger.Debug("Post Cookie", "name", cookie.Name, "cookie", cookie.Value)
 	}
 
 	return v.client.PostForm(v.cgiURL.String(), urlValues)
 }
 
+// post sends p once and returns the decoded response if the router
+// accepted it.
+func (v *Vigor) post(p vigorForm) (string, error) {
+	resp, err := v.postForm(p)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return "", fmt.Errorf("%w: %s", errUnauthorized, resp.Status)
+	}
+	respJSON, err := decodeVigorJSON(resp)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errUnauthorized, err)
+	}
+	if rid := gjson.Get(respJSON, "rid").String(); rid != "0000" {
+		return respJSON, fmt.Errorf("%w: rid %s", errRejected, rid)
+	}
+	return respJSON, nil
+}
+
+// postWithLogin posts p, logging in again and retrying if it fails. A request
+// counts as one relogin if the router rejected it, network errors are not
+// counted.
 func (v *Vigor) postWithLogin(p vigorForm) (string, error) {
-	var rid string
+	var (
+		lastErr  error
+		rejected bool
+	)
 	for attempts := range 3 {
-		resp, err := v.postForm(p)
-		if err == nil && resp.StatusCode == http.StatusOK {
-			respJSON, err := decodeVigorJSON(resp)
-			if err == nil {
-				rid = gjson.Get(respJSON, "rid").String()
-				if rid == "0000" {
-					resp.Body.Close()
-					return respJSON, nil
-				}
+		respJSON, err := v.post(p)
+		if err == nil {
+			return respJSON, nil
+		}
+		lastErr = err
+		if errors.Is(err, errUnauthorized) || errors.Is(err, errRejected) {
+			v.logger.Debug("Post failed, attempting login", "err", err)
+			if !rejected {
+				rejected = true
+				v.mtx.Lock()
+				v.relogins++
+				v.mtx.Unlock()
 			}
+		} else {
+			v.logger.Debug("Post failed", "err", err)
 		}
-		defer resp.Body.Close()
-		v.logger.Debug("Post failed, attempting login", "status", resp.Status, "err", err, "rid", rid)
 		err = v.Login()
 		if err != nil {
+			lastErr = err
 			v.logger.Debug("Login failed", "err", err)
+			// Retrying rejected credentials would run the credential helper
+			// again on every attempt, the next request retries them.
+			if errors.Is(err, ErrLoginFailed) || errors.Is(err, ErrCredentialsFailed) {
+				break
+			}
 		}
-		time.Sleep(time.Duration(attempts) * time.Second)
+		time.Sleep(time.Duration(attempts) * retryDelay)
 	}
-	return "", ErrRequestFailed
+	return "", fmt.Errorf("%w: %w", ErrRequestFailed, lastErr)
 }
 
 func decodeVigorJSON(resp *http.Response) (string, error) {
@@ -128,19 +261,23 @@ func decodeVigorJSON(resp *http.Response) (string, error) {
 	if err != nil {
 		return "", ErrJSONDecodeFailed
 	}
-	respPadding, err := strconv.Atoi(string(body[0]))
+	return decodeVigorString(string(body))
+}
+
+func decodeVigorString(body string) (string, error) {
+	if len(body) == 0 {
+		return "", ErrJSONDecodeFailed
+	}
+	respPadding, err := strconv.Atoi(body[:1])
 	if err != nil {
 		return "", ErrJSONDecodeFailed
 	}
 	if respPadding > 2 {
 		return "", ErrJSONDecodeFailed
 	}
-	body = body[1:]
-	for range respPadding {
-		body = append(body, []byte("=")...)
-	}
+	body = body[1:] + strings.Repeat("=", respPadding)
 
-	decoded, err := base64.StdEncoding.DecodeString(string(body))
+	decoded, err := base64.StdEncoding.DecodeString(body)
 	if err != nil {
 		return "", ErrJSONDecodeFailed
 	}
@@ -0,0 +1,83 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// unreachableTransport answers logins from the replay transport and fails
+// all other requests with a network error.
+type unreachableTransport struct {
+	next http.RoundTripper
+}
+
+func (t unreachableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	form, _ := url.ParseQuery(string(body))
+	if form.Get("op") != "552" {
+		return nil, syscall.ECONNREFUSED
+	}
+	req.Body = io.NopCloser(strings.NewReader(string(body)))
+	return t.next.RoundTrip(req)
+}
+
+func TestPostWithLoginRelogins(t *testing.T) {
+	defer func(delay time.Duration) {
+		retryDelay = delay
+	}(retryDelay)
+	retryDelay = 0
+
+	form := vigorForm{pid: "0SYSTEM_UNKNOWN", op: "501", ct: `{"param":[],"ct":[]}`}
+
+	// Every attempt is rejected with an error rid, the request counts as
+	// one relogin.
+	v := replayVigor(t, "testdata/query")
+	for i := range 2 {
+		if _, err := v.postWithLogin(form); !errors.Is(err, ErrRequestFailed) || !errors.Is(err, errRejected) {
+			t.Fatalf("got error %v, want %v", err, errRejected)
+		}
+		if got := v.Relogins(); got != uint64(i+1) {
+			t.Errorf("request %d: got %d relogins, want %d", i, got, i+1)
+		}
+	}
+
+	// Network errors are not counted.
+	rt, err := NewReplayTransport("testdata/query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = New(slog.New(slog.DiscardHandler), "192.0.2.1", "monitor", "secret", WithTransport(unreachableTransport{rt}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.postWithLogin(form); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("got error %v, want %v", err, syscall.ECONNREFUSED)
+	}
+	if got := v.Relogins(); got != 0 {
+		t.Errorf("got %d relogins after network errors, want 0", got)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	voipStatusGeneral = `{"param":[],"ct":[{"0MONITORING_VOIP_GENERAL":[]},{"1MON_VOIP_SIP_TABLE":[]},{"1MON_VOIP_PORT_TABLE":[]}]}`
+
+	voipTimeLayout = "2006-01-02 15:04:05"
+)
+
+type VoIPStatus struct {
+	Accounts []SIPAccount
+	Ports    []PhonePort
+}
+
+type SIPAccount struct {
+	Name      string
+	Registrar string
+
+	Registered       bool
+	LastRegistration time.Time
+
+	IncomingCalls int
+	OutgoingCalls int
+	FailedCalls   int
+}
+
+type PhonePort struct {
+	Name    string
+	OffHook bool
+}
+
+func (v *Vigor) FetchVoIPStatus() (VoIPStatus, error) {
+	post := vigorForm{
+		pid: "0MONITORING_VOIP_GENERAL",
+		op:  "501",
+		ct:  voipStatusGeneral,
+	}
+
+	resp, err := v.fetch(post)
+	if err != nil {
+		v.logger.Debug("Got error from post", "err", err)
+		return VoIPStatus{}, err
+	}
+
+	return v.parseVoIPStatusGeneralJSON(resp)
+}
+
+func (v *Vigor) parseVoIPStatusGeneralJSON(respJSON string) (VoIPStatus, error) {
+	value := gjson.Get(respJSON, "ct.0.0MONITORING_VOIP_GENERAL.#(Name==\"Setting\")")
+	if !value.Exists() {
+		v.logger.Debug("Unable to get settings", "response_json", respJSON)
+		return VoIPStatus{}, ErrParseFailed
+	}
+
+	v.logger.Debug("Parsed VoIP Status General json", "json", value.String())
+
+	location := v.location
+	if location == nil {
+		location = time.UTC
+	}
+
+	var status VoIPStatus
+
+	for _, v := range value.Get("SIP_Table").Array() {
+		status.Accounts = append(status.Accounts, SIPAccount{
+			Name:             v.Get("Account").String(),
+			Registrar:        v.Get("Registrar").String(),
+			Registered:       v.Get("Status").String() == "Registered",
+			LastRegistration: parseTime(v.Get("Last_Registration").String(), location),
+			IncomingCalls:    parseCount(v.Get("In_Calls").String()),
+			OutgoingCalls:    parseCount(v.Get("Out_Calls").String()),
+			FailedCalls:      parseCount(v.Get("Failed_Calls").String()),
+		})
+	}
+
+	for _, v := range value.Get("Port_Table").Array() {
+		status.Ports = append(status.Ports, PhonePort{
+			Name:    v.Get("Port").String(),
+			OffHook: v.Get("Hook").String() == "Off-Hook",
+		})
+	}
+
+	return status, nil
+}
+
+// parseTime parses a router timestamp in the router's time zone, returning the
+// zero time if it is not set.
+func parseTime(s string, location *time.Location) time.Time {
+	t, err := time.ParseInLocation(voipTimeLayout, s, location)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,99 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFetchVoIPStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		opts     []Option
+		lastTime time.Time
+	}{
+		{
+			name:     "utc",
+			lastTime: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "router time zone",
+			opts:     []Option{WithLocation(time.FixedZone("CET", 3600))},
+			lastTime: time.Date(2024, 3, 1, 11, 30, 0, 0, time.UTC),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rt, err := NewReplayTransport("../testdata/vigor_v5/voip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := New(slog.New(slog.DiscardHandler), "192.0.2.1", "monitor", "secret", append(tc.opts, WithTransport(rt))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			status, err := v.FetchVoIPStatus()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := status.Accounts[0].LastRegistration; !got.Equal(tc.lastTime) {
+				t.Errorf("got last registration %s, want %s", got, tc.lastTime)
+			}
+			status.Accounts[0].LastRegistration = time.Time{}
+
+			want := VoIPStatus{
+				Accounts: []SIPAccount{
+					{Name: "office", Registrar: "sip.example.com", Registered: true, IncomingCalls: 12, OutgoingCalls: 34, FailedCalls: 2},
+					{Name: "fax", Registrar: "sip.example.com", Registered: false, IncomingCalls: 0, OutgoingCalls: 0, FailedCalls: 5},
+				},
+				Ports: []PhonePort{
+					{Name: "Phone1", OffHook: true},
+					{Name: "Phone2", OffHook: false},
+				},
+			}
+			if !reflect.DeepEqual(status, want) {
+				t.Errorf("got  %+v\nwant %+v", status, want)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	cet := time.FixedZone("CET", 3600)
+	for _, tc := range []struct {
+		in       string
+		location *time.Location
+		want     time.Time
+	}{
+		{"2024-03-01 12:30:00", time.UTC, time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{"2024-03-01 12:30:00", cet, time.Date(2024, 3, 1, 11, 30, 0, 0, time.UTC)},
+		{"", time.UTC, time.Time{}},
+		{"N/A", time.UTC, time.Time{}},
+		{"2024-03-01T12:30:00Z", time.UTC, time.Time{}},
+	} {
+		if got := parseTime(tc.in, tc.location); !got.Equal(tc.want) {
+			t.Errorf("parseTime(%q, %s) = %s, want %s", tc.in, tc.location, got, tc.want)
+		}
+	}
+}
+
+func TestParseVoIPStatusMissingSettings(t *testing.T) {
+	_, err := testVigor().parseVoIPStatusGeneralJSON(`{"rid":"0000","ct":[{"0MONITORING_VOIP_GENERAL":[]}]}`)
+	if err != ErrParseFailed {
+		t.Errorf("got error %v, want %v", err, ErrParseFailed)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	wirelessStatusGeneral = `{"param":[],"ct":[{"0MONITORING_WIRELESS_GENERAL":[]},{"1MON_WIRELESS_RADIO_TABLE":[]},{"1MON_WIRELESS_SSID_TABLE":[]},{"1MON_WIRELESS_STATION_TABLE":[]}]}`
+)
+
+type WirelessStatus struct {
+	Radios  []WirelessRadio
+	SSIDs   []WirelessSSID
+	Clients []WirelessClient
+}
+
+type WirelessRadio struct {
+	Name string
+	Band string
+
+	Channel     int
+	Bandwidth   int
+	NoiseFloor  float64
+	Utilization float64
+}
+
+type WirelessSSID struct {
+	Radio   string
+	SSID    string
+	Clients int
+}
+
+type WirelessClient struct {
+	Radio string
+	SSID  string
+	MAC   string
+
+	RSSI    float64
+	TxRate  int
+	RxRate  int
+	TxBytes int
+	RxBytes int
+}
+
+func (v *Vigor) FetchWirelessStatus() (WirelessStatus, error) {
+	post := vigorForm{
+		pid: "0MONITORING_WIRELESS_GENERAL",
+		op:  "501",
+		ct:  wirelessStatusGeneral,
+	}
+
+	resp, err := v.fetch(post)
+	if err != nil {
+		v.logger.Debug("Got error from post", "err", err)
+		return WirelessStatus{}, err
+	}
+
+	return v.parseWirelessStatusGeneralJSON(resp)
+}
+
+func (v *Vigor) parseWirelessStatusGeneralJSON(respJSON string) (WirelessStatus, error) {
+	value := gjson.Get(respJSON, "ct.0.0MONITORING_WIRELESS_GENERAL.#(Name==\"Setting\")")
+	if !value.Exists() {
+		v.logger.Debug("Unable to get settings", "response_json", respJSON)
+		return WirelessStatus{}, ErrParseFailed
+	}
+
+	v.logger.Debug("Parsed Wireless Status General json", "json", value.String())
+
+	var status WirelessStatus
+
+	for _, v := range value.Get("Radio_Table").Array() {
+		status.Radios = append(status.Radios, WirelessRadio{
+			Name:        v.Get("Name").String(),
+			Band:        v.Get("Band").String(),
+			Channel:     parseCount(v.Get("Channel").String()),
+			Bandwidth:   parseMHz(v.Get("Bandwidth").String()),
+			NoiseFloor:  parsedBm(v.Get("Noise_Floor").String()),
+			Utilization: parsePercent(v.Get("Utilization").String()),
+		})
+	}
+
+	for _, v := range value.Get("SSID_Table").Array() {
+		status.SSIDs = append(status.SSIDs, WirelessSSID{
+			Radio:   v.Get("Radio").String(),
+			SSID:    v.Get("SSID").String(),
+			Clients: parseCount(v.Get("Clients").String()),
+		})
+	}
+
+	for _, v := range value.Get("Station_Table").Array() {
+		status.Clients = append(status.Clients, WirelessClient{
+			Radio:   v.Get("Radio").String(),
+			SSID:    v.Get("SSID").String(),
+			MAC:     strings.ToLower(v.Get("MAC").String()),
+			RSSI:    parsedBm(v.Get("RSSI").String()),
+			TxRate:  parseMbps(v.Get("Tx_Rate").String()),
+			RxRate:  parseMbps(v.Get("Rx_Rate").String()),
+			TxBytes: parseCount(v.Get("Tx_Bytes").String()),
+			RxBytes: parseCount(v.Get("Rx_Bytes").String()),
+		})
+	}
+
+	return status, nil
+}
+
+func parseMbps(s string) int {
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return 0
+	}
+	x, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0
+	}
+	return int(x * 1000000)
+}
+
+func parseMHz(s string) int {
+	parts := strings.Fields(strings.TrimSuffix(s, "MHz"))
+	if len(parts) != 1 {
+		return 0
+	}
+	x, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0
+	}
+	return x * 1000000
+}
+
+func parsePercent(s string) float64 {
+	x, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64)
+	if err != nil {
+		return 0
+	}
+	return x / 100
+}
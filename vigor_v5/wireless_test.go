@@ -0,0 +1,67 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vigorv5
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func testVigor() *Vigor {
+	return &Vigor{logger: slog.New(slog.DiscardHandler)}
+}
+
+func TestFetchWirelessStatusDualBand(t *testing.T) {
+	rt, err := NewReplayTransport("../testdata/vigor_v5/wireless_dual_band")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := New(slog.New(slog.DiscardHandler), "192.0.2.1", "monitor", "secret", WithTransport(rt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := v.FetchWirelessStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := WirelessStatus{
+		Radios: []WirelessRadio{
+			{Name: "wlan0", Band: "2.4GHz", Channel: 6, Bandwidth: 20000000, NoiseFloor: -95, Utilization: 0.42},
+			{Name: "wlan1", Band: "5GHz", Channel: 36, Bandwidth: 80000000, NoiseFloor: -92, Utilization: 0.075},
+		},
+		SSIDs: []WirelessSSID{
+			{Radio: "wlan0", SSID: "Office", Clients: 2},
+			{Radio: "wlan1", SSID: "Office", Clients: 1},
+			{Radio: "wlan1", SSID: "Guest", Clients: 0},
+		},
+		Clients: []WirelessClient{
+			{Radio: "wlan0", SSID: "Office", MAC: "aa:bb:cc:00:00:01", RSSI: -61, TxRate: 72200000, RxRate: 65000000, TxBytes: 123456, RxBytes: 654321},
+			{Radio: "wlan0", SSID: "Office", MAC: "aa:bb:cc:00:00:02", RSSI: -70, TxRate: 39000000, RxRate: 26000000, TxBytes: 1000, RxBytes: 2000},
+			{Radio: "wlan1", SSID: "Office", MAC: "aa:bb:cc:00:00:03", RSSI: -55, TxRate: 866700000, RxRate: 780000000, TxBytes: 99999999, RxBytes: 88888888},
+		},
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("got  %+v\nwant %+v", status, want)
+	}
+}
+
+func TestParseWirelessStatusMissingSettings(t *testing.T) {
+	_, err := testVigor().parseWirelessStatusGeneralJSON(`{"rid":"0000","ct":[{"0MONITORING_WIRELESS_GENERAL":[]}]}`)
+	if err != ErrParseFailed {
+		t.Errorf("got error %v, want %v", err, ErrParseFailed)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var voipTimezone = kingpin.Flag("collector.voip.timezone", "Time zone of the SIP registration times reported by the target, as an IANA name such as Europe/Berlin").Default("UTC").String()
+
+// voipLocation returns the time zone set by --collector.voip.timezone.
+func voipLocation() (*time.Location, error) {
+	return time.LoadLocation(*voipTimezone)
+}
+
+func init() {
+	registerCollector("voip", defaultDisabled, NewVoIPCollector)
+}
+
+// VoIPCollector collects SIP account and phone port stats from Vigor "V"
+// models.
+type VoIPCollector struct {
+	v      *vigorv5.Vigor
+	logger *slog.Logger
+}
+
+// NewVoIPCollector returns an initialized VoIPCollector.
+func NewVoIPCollector(device StatusFetcher, modules []config.Module, logger *slog.Logger) (Collector, error) {
+	v, ok := device.(*vigorv5.Vigor)
+	if !ok {
+		return nil, ErrUnsupportedDevice
+	}
+	return &VoIPCollector{v: v, logger: logger}, nil
+}
+
+var (
+	voipSIPRegisteredDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "voip", "sip_account_registered"),
+		"Whether the SIP account is registered",
+		[]string{"account", "registrar"}, nil,
+	)
+	voipSIPLastRegistrationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "voip", "sip_account_last_registration_timestamp_seconds"),
+		"The time of the last successful SIP registration",
+		[]string{"account"}, nil,
+	)
+	voipCallsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "voip", "calls_total"),
+		"The number of calls on the SIP account",
+		[]string{"account", "direction"}, nil,
+	)
+	voipFailedCallsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "voip", "failed_calls_total"),
+		"The number of failed calls on the SIP account",
+		[]string{"account"}, nil,
+	)
+	voipPortOffHookDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "voip", "port_off_hook"),
+		"Whether the phone port is off hook",
+		[]string{"port"}, nil,
+	)
+)
+
+// Update fetches the VoIP stats from the draytek router and delivers them as
+// Prometheus metrics.
+func (c *VoIPCollector) Update(ch chan<- prometheus.Metric) error {
+	status, err := c.v.FetchVoIPStatus()
+	if err != nil {
+		return err
+	}
+	for _, account := range status.Accounts {
+		ch <- prometheus.MustNewConstMetric(
+			voipSIPRegisteredDesc, prometheus.GaugeValue, optionToFloat64(account.Registered),
+			account.Name, account.Registrar,
+		)
+		if !account.LastRegistration.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				voipSIPLastRegistrationDesc, prometheus.GaugeValue, float64(account.LastRegistration.Unix()),
+				account.Name,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			voipCallsDesc, prometheus.CounterValue, float64(account.IncomingCalls),
+			account.Name, "incoming",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			voipCallsDesc, prometheus.CounterValue, float64(account.OutgoingCalls),
+			account.Name, "outgoing",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			voipFailedCallsDesc, prometheus.CounterValue, float64(account.FailedCalls),
+			account.Name,
+		)
+	}
+
+	for _, port := range status.Ports {
+		ch <- prometheus.MustNewConstMetric(
+			voipPortOffHookDesc, prometheus.GaugeValue, optionToFloat64(port.OffHook),
+			port.Name,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestVoIPCollector(t *testing.T) {
+	c := &VoIPCollector{
+		v:      replayVigor(t, "testdata/vigor_v5/voip"),
+		logger: slog.New(slog.DiscardHandler),
+	}
+
+	want := `
+# HELP draytek_voip_calls_total The number of calls on the SIP account
+# TYPE draytek_voip_calls_total counter
+draytek_voip_calls_total{account="fax",direction="incoming"} 0
+draytek_voip_calls_total{account="fax",direction="outgoing"} 0
+draytek_voip_calls_total{account="office",direction="incoming"} 12
+draytek_voip_calls_total{account="office",direction="outgoing"} 34
+# HELP draytek_voip_failed_calls_total The number of failed calls on the SIP account
+# TYPE draytek_voip_failed_calls_total counter
+draytek_voip_failed_calls_total{account="fax"} 5
+draytek_voip_failed_calls_total{account="office"} 2
+# HELP draytek_voip_port_off_hook Whether the phone port is off hook
+# TYPE draytek_voip_port_off_hook gauge
+draytek_voip_port_off_hook{port="Phone1"} 1
+draytek_voip_port_off_hook{port="Phone2"} 0
+# HELP draytek_voip_sip_account_last_registration_timestamp_seconds The time of the last successful SIP registration
+# TYPE draytek_voip_sip_account_last_registration_timestamp_seconds gauge
+draytek_voip_sip_account_last_registration_timestamp_seconds{account="office"} 1.7092962e+09
+# HELP draytek_voip_sip_account_registered Whether the SIP account is registered
+# TYPE draytek_voip_sip_account_registered gauge
+draytek_voip_sip_account_registered{account="fax",registrar="sip.example.com"} 0
+draytek_voip_sip_account_registered{account="office",registrar="sip.example.com"} 1
+`
+	if err := testutil.CollectAndCompare(updateCollector{c}, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/SuperQ/draytek_exporter/config"
+	vigorv5 "github.com/SuperQ/draytek_exporter/vigor_v5"
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	wirelessClients     = kingpin.Flag("collector.wireless.clients", "Export per-client wireless metrics").Default("false").Bool()
+	wirelessClientLimit = kingpin.Flag("collector.wireless.client-limit", "Maximum number of wireless clients to export per scrape").Default("100").Int()
+)
+
+func init() {
+	registerCollector("wireless", defaultDisabled, NewWirelessCollector)
+}
+
+// WirelessCollector collects Wi-Fi radio, SSID and client stats from Vigor
+// models with built-in wireless.
+type WirelessCollector struct {
+	v      *vigorv5.Vigor
+	logger *slog.Logger
+
+	clients     bool
+	clientLimit int
+}
+
+// NewWirelessCollector returns an initialized WirelessCollector.
+func NewWirelessCollector(device StatusFetcher, modules []config.Module, logger *slog.Logger) (Collector, error) {
+	if *wirelessClientLimit < 0 {
+		return nil, fmt.Errorf("--collector.wireless.client-limit must not be negative, got %d", *wirelessClientLimit)
+	}
+	v, ok := device.(*vigorv5.Vigor)
+	if !ok {
+		return nil, ErrUnsupportedDevice
+	}
+	return &WirelessCollector{
+		v:           v,
+		logger:      logger,
+		clients:     *wirelessClients,
+		clientLimit: *wirelessClientLimit,
+	}, nil
+}
+
+var (
+	wirelessRadioInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "radio_info"),
+		"Info about the wireless radio",
+		[]string{"radio", "band"}, nil,
+	)
+	wirelessRadioChannelDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "radio_channel"),
+		"The channel the wireless radio is operating on",
+		[]string{"radio"}, nil,
+	)
+	wirelessRadioBandwidthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "radio_bandwidth_hz"),
+		"The channel bandwidth of the wireless radio in Hz",
+		[]string{"radio"}, nil,
+	)
+	wirelessRadioNoiseFloorDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "radio_noise_floor_dbm"),
+		"The noise floor of the wireless radio in dBm",
+		[]string{"radio"}, nil,
+	)
+	wirelessRadioUtilizationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "radio_utilization_ratio"),
+		"The channel utilization of the wireless radio",
+		[]string{"radio"}, nil,
+	)
+	wirelessSSIDClientsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "ssid_clients"),
+		"The number of clients associated to the SSID",
+		[]string{"radio", "ssid"}, nil,
+	)
+
+	wirelessClientRSSIDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "client_rssi_dbm"),
+		"The received signal strength of the client in dBm",
+		[]string{"radio", "ssid", "mac"}, nil,
+	)
+	wirelessClientTxRateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "client_transmit_phy_rate_bps"),
+		"The PHY rate used to transmit to the client in bits per second",
+		[]string{"radio", "ssid", "mac"}, nil,
+	)
+	wirelessClientRxRateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "client_receive_phy_rate_bps"),
+		"The PHY rate used to receive from the client in bits per second",
+		[]string{"radio", "ssid", "mac"}, nil,
+	)
+	wirelessClientTxBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "client_transmit_bytes_total"),
+		"The number of bytes transmitted to the client",
+		[]string{"radio", "ssid", "mac"}, nil,
+	)
+	wirelessClientRxBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "client_receive_bytes_total"),
+		"The number of bytes received from the client",
+		[]string{"radio", "ssid", "mac"}, nil,
+	)
+	wirelessClientsDroppedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "wireless", "clients_dropped"),
+		"The number of clients not exported due to the client limit",
+		nil, nil,
+	)
+)
+
+// Update fetches the wireless stats from the draytek router and delivers them
+// as Prometheus metrics.
+func (c *WirelessCollector) Update(ch chan<- prometheus.Metric) error {
+	status, err := c.v.FetchWirelessStatus()
+	if err != nil {
+		return err
+	}
+	for _, radio := range status.Radios {
+		ch <- prometheus.MustNewConstMetric(
+			wirelessRadioInfoDesc, prometheus.GaugeValue, 1.0,
+			radio.Name, radio.Band,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			wirelessRadioChannelDesc, prometheus.GaugeValue, float64(radio.Channel),
+			radio.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			wirelessRadioBandwidthDesc, prometheus.GaugeValue, float64(radio.Bandwidth),
+			radio.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			wirelessRadioNoiseFloorDesc, prometheus.GaugeValue, radio.NoiseFloor,
+			radio.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			wirelessRadioUtilizationDesc, prometheus.GaugeValue, radio.Utilization,
+			radio.Name,
+		)
+	}
+
+	for _, ssid := range status.SSIDs {
+		ch <- prometheus.MustNewConstMetric(
+			wirelessSSIDClientsDesc, prometheus.GaugeValue, float64(ssid.Clients),
+			ssid.Radio, ssid.SSID,
+		)
+	}
+
+	if !c.clients {
+		return nil
+	}
+
+	clients := status.Clients
+	dropped := 0
+	if len(clients) > c.clientLimit {
+		dropped = len(clients) - c.clientLimit
+		clients = clients[:c.clientLimit]
+		c.logger.Debug("Wireless client limit exceeded", "limit", c.clientLimit, "dropped", dropped)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		wirelessClientsDroppedDesc, prometheus.GaugeValue, float64(dropped),
+	)
+
+	for _, client := range clients {
+		ch <- prometheus.MustNewConstMetric(
+			wirelessClientRSSIDesc, prometheus.GaugeValue, client.RSSI,
+			client.Radio, client.SSID, client.MAC,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			wirelessClientTxRateDesc, prometheus.GaugeValue, float64(client.TxRate),
+			client.Radio, client.SSID, client.MAC,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			wirelessClientRxRateDesc, prometheus.GaugeValue, float64(client.RxRate),
+			client.Radio, client.SSID, client.MAC,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			wirelessClientTxBytesDesc, prometheus.CounterValue, float64(client.TxBytes),
+			client.Radio, client.SSID, client.MAC,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			wirelessClientRxBytesDesc, prometheus.CounterValue, float64(client.RxBytes),
+			client.Radio, client.SSID, client.MAC,
+		)
+	}
+	return nil
+}
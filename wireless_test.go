@@ -0,0 +1,70 @@
+// Copyright Ben Kochie <superq@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWirelessCollectorDualBand(t *testing.T) {
+	c := &WirelessCollector{
+		v:           replayVigor(t, "testdata/vigor_v5/wireless_dual_band"),
+		logger:      slog.New(slog.DiscardHandler),
+		clients:     true,
+		clientLimit: 2,
+	}
+
+	want := `
+# HELP draytek_wireless_radio_channel The channel the wireless radio is operating on
+# TYPE draytek_wireless_radio_channel gauge
+draytek_wireless_radio_channel{radio="wlan0"} 6
+draytek_wireless_radio_channel{radio="wlan1"} 36
+# HELP draytek_wireless_ssid_clients The number of clients associated to the SSID
+# TYPE draytek_wireless_ssid_clients gauge
+draytek_wireless_ssid_clients{radio="wlan0",ssid="Office"} 2
+draytek_wireless_ssid_clients{radio="wlan1",ssid="Guest"} 0
+draytek_wireless_ssid_clients{radio="wlan1",ssid="Office"} 1
+# HELP draytek_wireless_client_rssi_dbm The received signal strength of the client in dBm
+# TYPE draytek_wireless_client_rssi_dbm gauge
+draytek_wireless_client_rssi_dbm{mac="aa:bb:cc:00:00:01",radio="wlan0",ssid="Office"} -61
+draytek_wireless_client_rssi_dbm{mac="aa:bb:cc:00:00:02",radio="wlan0",ssid="Office"} -70
+# HELP draytek_wireless_clients_dropped The number of clients not exported due to the client limit
+# TYPE draytek_wireless_clients_dropped gauge
+draytek_wireless_clients_dropped 1
+`
+	err := testutil.CollectAndCompare(updateCollector{c}, strings.NewReader(want),
+		"draytek_wireless_radio_channel",
+		"draytek_wireless_ssid_clients",
+		"draytek_wireless_client_rssi_dbm",
+		"draytek_wireless_clients_dropped",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWirelessCollectorNegativeClientLimit(t *testing.T) {
+	limit := *wirelessClientLimit
+	defer func() { *wirelessClientLimit = limit }()
+	*wirelessClientLimit = -1
+
+	_, err := NewWirelessCollector(nil, nil, slog.New(slog.DiscardHandler))
+	if err == nil {
+		t.Error("expected error for negative client limit")
+	}
+}